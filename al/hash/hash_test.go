@@ -0,0 +1,28 @@
+package hash
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMurmur3Sum128(t *testing.T) {
+	h1, h2 := Murmur3Sum128(nil, 0)
+	assert.Equal(t, uint64(0), h1)
+	assert.Equal(t, uint64(0), h2)
+
+	h1, h2 = Murmur3Sum128([]byte("The quick brown fox jumps over the lazy dog"), 0)
+	assert.Equal(t, uint64(0xe34bbc7bbc071b6c), h1)
+	assert.Equal(t, uint64(0x7a433ca9c49a9347), h2)
+
+	a1, a2 := Murmur3Sum128([]byte("hello"), 1)
+	b1, b2 := Murmur3Sum128([]byte("hello"), 2)
+	assert.NotEqual(t, a1, b1)
+	assert.NotEqual(t, a2, b2)
+}
+
+func TestGetHashInts(t *testing.T) {
+	ints := GetHashInts([]byte("hello"), 10)
+	assert.Equal(t, 10, len(ints))
+	assert.Equal(t, ints, GetHashInts([]byte("hello"), 10))
+}
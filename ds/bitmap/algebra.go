@@ -0,0 +1,123 @@
+package bitmap
+
+import "encoding/binary"
+
+// The set operations treat the shorter of two bitmaps as if it were padded with zeros.
+// The in-place forms grow the receiver to the size of the larger one, and the allocating
+// forms return a bitmap of that size, so no set bit is ever dropped.
+
+// And keeps only the bits also set in other.
+func (b *Bitmap) And(other *Bitmap) {
+	b.combine(other, func(x, y uint64) uint64 { return x & y })
+}
+
+// Or sets the bits set in other.
+func (b *Bitmap) Or(other *Bitmap) {
+	b.combine(other, func(x, y uint64) uint64 { return x | y })
+}
+
+// Xor flips the bits set in other.
+func (b *Bitmap) Xor(other *Bitmap) {
+	b.combine(other, func(x, y uint64) uint64 { return x ^ y })
+}
+
+// AndNot clears the bits set in other.
+func (b *Bitmap) AndNot(other *Bitmap) {
+	b.combine(other, func(x, y uint64) uint64 { return x &^ y })
+}
+
+// Not flips every bit up to Size().
+func (b *Bitmap) Not() {
+	for i := 0; i < b.words(); i++ {
+		b.storeWord(i, ^b.loadWord(i))
+	}
+}
+
+// Equal reports whether b and other have the same bits set. Sizes may differ
+// as long as the extra bits of the larger bitmap are all clear.
+func (b *Bitmap) Equal(other *Bitmap) bool {
+	words := b.words()
+	if other.words() > words {
+		words = other.words()
+	}
+	for i := 0; i < words; i++ {
+		if b.loadWord(i) != other.loadWord(i) {
+			return false
+		}
+	}
+	return true
+}
+
+func And(a, b *Bitmap) *Bitmap {
+	ret := a.clone()
+	ret.And(b)
+	return ret
+}
+
+func Or(a, b *Bitmap) *Bitmap {
+	ret := a.clone()
+	ret.Or(b)
+	return ret
+}
+
+func Xor(a, b *Bitmap) *Bitmap {
+	ret := a.clone()
+	ret.Xor(b)
+	return ret
+}
+
+func AndNot(a, b *Bitmap) *Bitmap {
+	ret := a.clone()
+	ret.AndNot(b)
+	return ret
+}
+
+func Not(a *Bitmap) *Bitmap {
+	ret := a.clone()
+	ret.Not()
+	return ret
+}
+
+func (b *Bitmap) clone() *Bitmap {
+	return NewFromBits(b.data)
+}
+
+func (b *Bitmap) combine(other *Bitmap, op func(x, y uint64) uint64) {
+	if other.size > b.size {
+		b.Resize(other.size)
+	}
+	for i := 0; i < b.words(); i++ {
+		b.storeWord(i, op(b.loadWord(i), other.loadWord(i)))
+	}
+}
+
+// words returns the number of 64-bit words covering the data, counting a partial last one.
+func (b *Bitmap) words() int {
+	return (len(b.data) + 7) / 8
+}
+
+// loadWord returns the i-th little endian 64-bit word of the data, zero beyond its end.
+func (b *Bitmap) loadWord(i int) uint64 {
+	start := i * 8
+	if start+8 <= len(b.data) {
+		return binary.LittleEndian.Uint64(b.data[start:])
+	}
+	var buf [8]byte
+	if start < len(b.data) {
+		copy(buf[:], b.data[start:])
+	}
+	return binary.LittleEndian.Uint64(buf[:])
+}
+
+// storeWord writes the i-th word, dropping the bytes beyond the end of the data.
+func (b *Bitmap) storeWord(i int, w uint64) {
+	b.rank = nil
+	start := i * 8
+	if start+8 <= len(b.data) {
+		binary.LittleEndian.PutUint64(b.data[start:], w)
+		return
+	}
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], w)
+	copy(b.data[start:], buf[:])
+}
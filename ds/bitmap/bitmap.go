@@ -3,6 +3,8 @@ package bitmap
 type Bitmap struct {
 	data []byte
 	size uint64
+	// rank is the optional directory built by BuildRankIndex, nil once the bitmap changes.
+	rank []uint64
 }
 
 func New(size uint64) *Bitmap {
@@ -23,11 +25,21 @@ func NewFromBits(data []byte) *Bitmap {
 	return bitmap
 }
 
+// Wrap returns a bitmap that uses data as its storage without copying it, so changes
+// are visible through data. Resize and Clear give the bitmap new storage.
+func Wrap(data []byte) *Bitmap {
+	return &Bitmap{
+		size: uint64(len(data)) * 8,
+		data: data,
+	}
+}
+
 func (b *Bitmap) Set(pos uint64) bool {
 	if pos >= b.size {
 		return false
 	}
 	b.data[pos>>3] |= 1 << (pos & 0x07)
+	b.rank = nil
 	return true
 }
 
@@ -36,6 +48,7 @@ func (b *Bitmap) Unset(pos uint64) bool {
 		return false
 	}
 	b.data[pos>>3] &= ^(1 << (byte(pos) & 0x07))
+	b.rank = nil
 	return true
 }
 
@@ -58,6 +71,7 @@ func (b *Bitmap) Resize(size uint64) bool {
 	copy(data, b.data)
 	b.data = data
 	b.size = size
+	b.rank = nil
 	return true
 }
 
@@ -67,6 +81,7 @@ func (b *Bitmap) Size() uint64 {
 
 func (b *Bitmap) Clear() {
 	b.data = make([]byte, b.size/8)
+	b.rank = nil
 }
 
 func (b *Bitmap) Data() []byte {
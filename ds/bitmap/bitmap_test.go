@@ -57,3 +57,226 @@ func TestError(t *testing.T) {
 	assert.False(t, a.IsSet(1024))
 	assert.False(t, a.Resize(1024))
 }
+
+func TestWrap(t *testing.T) {
+	data := make([]byte, 2)
+	b := Wrap(data)
+	assert.Equal(t, uint64(16), b.Size())
+	b.Set(9)
+	assert.Equal(t, byte(2), data[1])
+	data[0] = 1
+	assert.True(t, b.IsSet(0))
+}
+
+func TestAlgebra(t *testing.T) {
+	a, b := New(100), New(100)
+	for i := uint64(0); i < 100; i++ {
+		if i%2 == 0 {
+			a.Set(i)
+		}
+		if i%3 == 0 {
+			b.Set(i)
+		}
+	}
+	check := func(bm *Bitmap, expected func(i uint64) bool) {
+		for i := uint64(0); i < bm.Size(); i++ {
+			assert.Equal(t, expected(i), bm.IsSet(i), i)
+		}
+	}
+	check(And(a, b), func(i uint64) bool { return i < 100 && i%6 == 0 })
+	check(Or(a, b), func(i uint64) bool { return i < 100 && (i%2 == 0 || i%3 == 0) })
+	check(Xor(a, b), func(i uint64) bool { return i < 100 && (i%2 == 0) != (i%3 == 0) })
+	check(AndNot(a, b), func(i uint64) bool { return i < 100 && i%2 == 0 && i%3 != 0 })
+	check(Not(a), func(i uint64) bool { return i >= 100 || i%2 == 1 })
+	// the allocating forms leave their operands alone
+	check(a, func(i uint64) bool { return i < 100 && i%2 == 0 })
+
+	c := NewFromBits(a.Data())
+	c.Xor(a)
+	assert.True(t, c.Equal(New(8)))
+	c.Not()
+	c.Not()
+	assert.True(t, c.Equal(New(0)))
+	assert.False(t, a.Equal(b))
+	assert.True(t, a.Equal(NewFromBits(a.Data())))
+}
+
+func TestAlgebraSizes(t *testing.T) {
+	small, large := New(8), New(200)
+	small.Set(3)
+	large.Set(3)
+	large.Set(150)
+
+	// the shorter bitmap is padded with zeros and the result has the larger size
+	assert.Equal(t, uint64(200), And(small, large).Size())
+	assert.True(t, And(small, large).Equal(small))
+	assert.True(t, Or(small, large).Equal(large))
+	xor := Xor(small, large)
+	assert.False(t, xor.IsSet(3))
+	assert.True(t, xor.IsSet(150))
+	assert.True(t, AndNot(large, small).IsSet(150))
+	assert.False(t, AndNot(large, small).IsSet(3))
+
+	small.Or(large)
+	assert.Equal(t, uint64(200), small.Size())
+	assert.True(t, small.Equal(large))
+
+	padded := New(1000)
+	padded.Set(3)
+	assert.True(t, padded.Equal(NewFromBits([]byte{8})))
+	assert.True(t, NewFromBits([]byte{8}).Equal(padded))
+	padded.Set(999)
+	assert.False(t, NewFromBits([]byte{8}).Equal(padded))
+}
+
+func TestRankSelect(t *testing.T) {
+	b := New(5000)
+	positions := make([]uint64, 0)
+	for i := uint64(0); i < 5000; i += 7 {
+		if i%3 != 0 {
+			b.Set(i)
+			positions = append(positions, i)
+		}
+	}
+	check := func() {
+		assert.Equal(t, uint64(len(positions)), b.Count())
+		for j, pos := range positions {
+			assert.Equal(t, uint64(j), b.Rank(pos))
+			assert.Equal(t, uint64(j+1), b.Rank(pos+1))
+			p, ok := b.Select(uint64(j))
+			assert.True(t, ok)
+			assert.Equal(t, pos, p)
+		}
+		_, ok := b.Select(uint64(len(positions)))
+		assert.False(t, ok)
+		assert.Equal(t, uint64(0), b.Rank(0))
+		assert.Equal(t, b.Count(), b.Rank(b.Size()+100))
+	}
+	check()
+	b.BuildRankIndex()
+	check()
+
+	// a change drops the directory
+	b.Set(1)
+	positions = append([]uint64{1}, positions...)
+	check()
+	b.BuildRankIndex()
+	b.Xor(NewFromBits([]byte{2}))
+	positions = positions[1:]
+	check()
+
+	empty := New(0)
+	empty.BuildRankIndex()
+	assert.Equal(t, uint64(0), empty.Count())
+	_, ok := empty.Select(0)
+	assert.False(t, ok)
+}
+
+func TestScan(t *testing.T) {
+	b := New(1000)
+	set := []uint64{0, 5, 63, 64, 200, 511, 512, 999}
+	for _, pos := range set {
+		b.Set(pos)
+	}
+
+	visited := make([]uint64, 0)
+	b.TraversalSet(func(pos uint64) bool {
+		visited = append(visited, pos)
+		return true
+	})
+	assert.Equal(t, set, visited)
+	visited = visited[:0]
+	b.TraversalSet(func(pos uint64) bool {
+		visited = append(visited, pos)
+		return len(visited) < 3
+	})
+	assert.Equal(t, set[:3], visited)
+
+	for from := uint64(0); from < 1010; from++ {
+		next, nextOk := b.NextSet(from)
+		prev, prevOk := b.PrevSet(from)
+		clear, clearOk := b.NextClear(from)
+		expectedNext, expectedPrev := -1, -1
+		for _, pos := range set {
+			if pos >= from && expectedNext < 0 {
+				expectedNext = int(pos)
+			}
+			if pos <= from {
+				expectedPrev = int(pos)
+			}
+		}
+		assert.Equal(t, expectedNext >= 0, nextOk, from)
+		if nextOk {
+			assert.Equal(t, uint64(expectedNext), next, from)
+		}
+		assert.Equal(t, expectedPrev >= 0, prevOk, from)
+		if prevOk {
+			assert.Equal(t, uint64(expectedPrev), prev, from)
+		}
+		assert.Equal(t, from < 999, clearOk, from)
+		if clearOk {
+			assert.False(t, b.IsSet(clear))
+			assert.GreaterOrEqual(t, clear, from)
+			for i := from; i < clear; i++ {
+				assert.True(t, b.IsSet(i))
+			}
+		}
+	}
+
+	full := NewFromBits([]byte{255, 255, 255})
+	_, ok := full.NextClear(0)
+	assert.False(t, ok)
+	_, ok = New(0).PrevSet(5)
+	assert.False(t, ok)
+	_, ok = New(64).PrevSet(5)
+	assert.False(t, ok)
+}
+
+func TestRange(t *testing.T) {
+	b := New(1000)
+	expected := make([]bool, 1000)
+	apply := func(lo, hi uint64, op func(bool) bool) {
+		for i := lo; i < hi; i++ {
+			expected[i] = op(expected[i])
+		}
+	}
+	check := func() {
+		for i := uint64(0); i < 1000; i++ {
+			assert.Equal(t, expected[i], b.IsSet(i), i)
+		}
+	}
+
+	assert.True(t, b.SetRange(3, 700))
+	apply(3, 700, func(bool) bool { return true })
+	check()
+	assert.True(t, b.ClearRange(64, 128))
+	apply(64, 128, func(bool) bool { return false })
+	check()
+	assert.True(t, b.FlipRange(10, 13))
+	apply(10, 13, func(v bool) bool { return !v })
+	check()
+	assert.True(t, b.FlipRange(0, 1000))
+	apply(0, 1000, func(v bool) bool { return !v })
+	check()
+	assert.True(t, b.SetRange(500, 500))
+	check()
+
+	for _, r := range [][2]uint64{{0, 1000}, {0, 1}, {5, 70}, {64, 128}, {63, 65}, {999, 1000}, {300, 300}} {
+		count := uint64(0)
+		for i := r[0]; i < r[1]; i++ {
+			if expected[i] {
+				count++
+			}
+		}
+		got, ok := b.CountRange(r[0], r[1])
+		assert.True(t, ok)
+		assert.Equal(t, count, got, r)
+	}
+
+	assert.False(t, b.SetRange(0, 1001))
+	assert.False(t, b.ClearRange(10, 5))
+	assert.False(t, b.FlipRange(1000, 1001))
+	_, ok := b.CountRange(0, 1001)
+	assert.False(t, ok)
+	check()
+}
@@ -0,0 +1,56 @@
+package bitmap
+
+import "math/bits"
+
+// The range operations cover [lo, hi). Like Set, they return false and change nothing
+// if the range does not lie within Size(), or if lo > hi.
+
+func (b *Bitmap) SetRange(lo, hi uint64) bool {
+	return b.updateRange(lo, hi, func(w, mask uint64) uint64 { return w | mask })
+}
+
+func (b *Bitmap) ClearRange(lo, hi uint64) bool {
+	return b.updateRange(lo, hi, func(w, mask uint64) uint64 { return w &^ mask })
+}
+
+func (b *Bitmap) FlipRange(lo, hi uint64) bool {
+	return b.updateRange(lo, hi, func(w, mask uint64) uint64 { return w ^ mask })
+}
+
+// CountRange returns the number of set bits in [lo, hi).
+func (b *Bitmap) CountRange(lo, hi uint64) (uint64, bool) {
+	count := 0
+	ok := b.visitRange(lo, hi, func(i int, mask uint64) {
+		count += bits.OnesCount64(b.loadWord(i) & mask)
+	})
+	return uint64(count), ok
+}
+
+func (b *Bitmap) updateRange(lo, hi uint64, op func(w, mask uint64) uint64) bool {
+	return b.visitRange(lo, hi, func(i int, mask uint64) {
+		b.storeWord(i, op(b.loadWord(i), mask))
+	})
+}
+
+// visitRange calls visitor with every word overlapping [lo, hi) and the mask of its bits
+// inside the range; all words in the middle get a full mask.
+func (b *Bitmap) visitRange(lo, hi uint64, visitor func(i int, mask uint64)) bool {
+	if lo > hi || hi > b.size {
+		return false
+	}
+	if lo == hi {
+		return true
+	}
+	first, last := int(lo/64), int((hi-1)/64)
+	for i := first; i <= last; i++ {
+		mask := ^uint64(0)
+		if i == first {
+			mask &= ^uint64(0) << (lo % 64)
+		}
+		if i == last {
+			mask &= ^uint64(0) >> (63 - (hi-1)%64)
+		}
+		visitor(i, mask)
+	}
+	return true
+}
@@ -0,0 +1,94 @@
+package bitmap
+
+import "math/bits"
+
+// rankBlockWords is the number of words a rank directory entry covers, 512 bits.
+const rankBlockWords = 8
+
+// BuildRankIndex precomputes a rank directory, one cumulative count per 512 bits, making Rank
+// O(1) and Select O(log n) at a memory cost of 1/8 of the bitmap. Any change to the bitmap
+// through its methods drops the directory; changes made directly to Data() or the slice
+// given to Wrap do not, so rebuild it after those.
+func (b *Bitmap) BuildRankIndex() {
+	words := b.words()
+	rank := make([]uint64, (words+rankBlockWords-1)/rankBlockWords+1)
+	count := uint64(0)
+	for i := 0; i < words; i++ {
+		if i%rankBlockWords == 0 {
+			rank[i/rankBlockWords] = count
+		}
+		count += uint64(bits.OnesCount64(b.loadWord(i)))
+	}
+	rank[len(rank)-1] = count
+	b.rank = rank
+}
+
+// Count returns the number of set bits.
+func (b *Bitmap) Count() uint64 {
+	if b.rank != nil {
+		return b.rank[len(b.rank)-1]
+	}
+	count := 0
+	for i := 0; i < b.words(); i++ {
+		count += bits.OnesCount64(b.loadWord(i))
+	}
+	return uint64(count)
+}
+
+// Rank returns the number of set bits before pos. A pos beyond Size() counts every set bit.
+func (b *Bitmap) Rank(pos uint64) uint64 {
+	if pos >= b.size {
+		return b.Count()
+	}
+	word := int(pos / 64)
+	count := uint64(0)
+	start := 0
+	if b.rank != nil {
+		start = word / rankBlockWords * rankBlockWords
+		count = b.rank[word/rankBlockWords]
+	}
+	for i := start; i < word; i++ {
+		count += uint64(bits.OnesCount64(b.loadWord(i)))
+	}
+	mask := uint64(1)<<(pos%64) - 1
+	return count + uint64(bits.OnesCount64(b.loadWord(word)&mask))
+}
+
+// Select returns the position of the j-th set bit, counting from 0.
+// It returns false if fewer than j+1 bits are set.
+func (b *Bitmap) Select(j uint64) (uint64, bool) {
+	word, remaining := 0, j
+	if b.rank != nil {
+		if j >= b.rank[len(b.rank)-1] {
+			return 0, false
+		}
+		// the last directory entry whose count is <= j holds the j-th bit
+		lo, hi := 0, len(b.rank)-2
+		for lo < hi {
+			mid := lo + (hi-lo+1)/2
+			if b.rank[mid] <= j {
+				lo = mid
+			} else {
+				hi = mid - 1
+			}
+		}
+		word, remaining = lo*rankBlockWords, j-b.rank[lo]
+	}
+	for ; word < b.words(); word++ {
+		w := b.loadWord(word)
+		count := uint64(bits.OnesCount64(w))
+		if remaining < count {
+			return uint64(word)*64 + selectInWord(w, remaining), true
+		}
+		remaining -= count
+	}
+	return 0, false
+}
+
+// selectInWord returns the position of the j-th set bit of w, which must have more than j set bits.
+func selectInWord(w uint64, j uint64) uint64 {
+	for ; j > 0; j-- {
+		w &= w - 1
+	}
+	return uint64(bits.TrailingZeros64(w))
+}
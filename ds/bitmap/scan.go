@@ -0,0 +1,82 @@
+package bitmap
+
+import (
+	"goalds/utils/visitor"
+	"math/bits"
+)
+
+// NextSet returns the position of the first set bit at or after from.
+// It returns false if there is none.
+func (b *Bitmap) NextSet(from uint64) (uint64, bool) {
+	if from >= b.size {
+		return 0, false
+	}
+	i := int(from / 64)
+	w := b.loadWord(i) & (^uint64(0) << (from % 64))
+	for {
+		if w != 0 {
+			return uint64(i)*64 + uint64(bits.TrailingZeros64(w)), true
+		}
+		i++
+		if i >= b.words() {
+			return 0, false
+		}
+		w = b.loadWord(i)
+	}
+}
+
+// NextClear returns the position of the first clear bit at or after from, below Size().
+// It returns false if there is none.
+func (b *Bitmap) NextClear(from uint64) (uint64, bool) {
+	if from >= b.size {
+		return 0, false
+	}
+	i := int(from / 64)
+	w := ^b.loadWord(i) & (^uint64(0) << (from % 64))
+	for {
+		if w != 0 {
+			pos := uint64(i)*64 + uint64(bits.TrailingZeros64(w))
+			return pos, pos < b.size
+		}
+		i++
+		if i >= b.words() {
+			return 0, false
+		}
+		w = ^b.loadWord(i)
+	}
+}
+
+// PrevSet returns the position of the last set bit at or before from.
+// It returns false if there is none.
+func (b *Bitmap) PrevSet(from uint64) (uint64, bool) {
+	if b.size == 0 {
+		return 0, false
+	}
+	if from >= b.size {
+		from = b.size - 1
+	}
+	i := int(from / 64)
+	w := b.loadWord(i) & (^uint64(0) >> (63 - from%64))
+	for {
+		if w != 0 {
+			return uint64(i)*64 + 63 - uint64(bits.LeadingZeros64(w)), true
+		}
+		i--
+		if i < 0 {
+			return 0, false
+		}
+		w = b.loadWord(i)
+	}
+}
+
+// TraversalSet calls visitor with the position of every set bit in ascending order,
+// until visitor returns false. Empty words are skipped as a whole.
+func (b *Bitmap) TraversalSet(visitor visitor.KVisitor[uint64]) {
+	for i := 0; i < b.words(); i++ {
+		for w := b.loadWord(i); w != 0; w &= w - 1 {
+			if !visitor(uint64(i)*64 + uint64(bits.TrailingZeros64(w))) {
+				return
+			}
+		}
+	}
+}
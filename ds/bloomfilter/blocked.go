@@ -0,0 +1,183 @@
+package bloomfilter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"goalds/al/hash"
+	"goalds/utils/locker"
+	"math"
+	"math/bits"
+)
+
+// blockWords is the number of uint64 words of a block, 64 bytes, one cache line.
+const blockWords = 8
+
+// blockSalts are the odd constants of the split block Bloom filter of Apache Parquet and Impala.
+// Each word of a block gets one bit, picked by multiplying the key hash with its salt.
+var blockSalts = [blockWords]uint32{
+	0x47b6137b, 0x44974d91, 0x8824ad5b, 0xa2b7289d,
+	0x705495c7, 0x2df1424b, 0x9efc4947, 0x5c6bfb31,
+}
+
+// BlockedBloomfilter is a split block Bloom filter (Putze et al., 2007). All bits of a value
+// lie in one 64-byte block, one bit in each of its 8 words, so Add and Test touch a single
+// cache line instead of k random ones and work on whole words with no data-dependent branch.
+// The price is a higher false positive rate for the same memory, since keys are not spread
+// evenly over blocks; use EstimateBlockedParameters rather than EstimateParameters to size it.
+// Positions always come from MurmurHash3, WithHashAlgorithm is ignored.
+type BlockedBloomfilter struct {
+	blocks uint64
+	words  []uint64
+	l      locker.Locker
+}
+
+// NewBlocked creates a blocked Bloom filter of blocks 512-bit blocks.
+func NewBlocked(blocks uint64, opts ...Option) *BlockedBloomfilter {
+	opt := &Options{locker: defaultLocker}
+	for _, o := range opts {
+		o(opt)
+	}
+	if blocks == 0 {
+		blocks = 1
+	}
+	return &BlockedBloomfilter{
+		blocks: blocks,
+		words:  make([]uint64, blocks*blockWords),
+		l:      opt.locker,
+	}
+}
+
+// NewBlockedWithEstimates creates a blocked Bloom filter for n elements with the false positive rate fp.
+func NewBlockedWithEstimates(n uint64, fp float64, opts ...Option) *BlockedBloomfilter {
+	return NewBlocked(EstimateBlockedParameters(n, fp), opts...)
+}
+
+// NewBlockedFromData restores a filter from BlockedBloomfilter.Data(). It returns nil if data is truncated.
+func NewBlockedFromData(data []byte, opts ...Option) *BlockedBloomfilter {
+	reader := bytes.NewReader(data)
+	var blocks uint64
+	if err := binary.Read(reader, binary.LittleEndian, &blocks); err != nil {
+		return nil
+	}
+	if blocks == 0 || blocks > uint64(reader.Len()) || uint64(reader.Len()) != blocks*blockWords*8 {
+		return nil
+	}
+	bbf := NewBlocked(blocks, opts...)
+	binary.Read(reader, binary.LittleEndian, bbf.words)
+	return bbf
+}
+
+// EstimateBlockedParameters returns the number of blocks for n elements with the false positive rate fp.
+// A blocked filter needs more memory than a standard one, because the fuller blocks dominate
+// the false positives and every value sets exactly 8 bits: about 5% more at fp = 0.01,
+// 10% more at 0.001 and over 20% more at 0.1 or 0.0001.
+func EstimateBlockedParameters(n uint64, fp float64) uint64 {
+	m, _ := EstimateParameters(n, fp)
+	lo, hi := uint64(1), (m+blockWords*64-1)/(blockWords*64)
+	if hi < 1 {
+		hi = 1
+	}
+	for BlockedFalsePositiveRate(n, hi) > fp {
+		lo, hi = hi, hi*2
+	}
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		if BlockedFalsePositiveRate(n, mid) > fp {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	return hi
+}
+
+// BlockedFalsePositiveRate returns the expected false positive rate of a blocked filter of blocks
+// blocks holding n elements. The number of elements in a block follows a Poisson distribution
+// of mean n/blocks, and a block holding i elements has the rate (1 - (1-1/64)^i)^8.
+func BlockedFalsePositiveRate(n uint64, blocks uint64) float64 {
+	lambda := float64(n) / float64(blocks)
+	if lambda == 0 {
+		return 0
+	}
+	rate := 0.0
+	last := int(lambda + 10*math.Sqrt(lambda) + 10)
+	for i := 0; i <= last; i++ {
+		lgamma, _ := math.Lgamma(float64(i + 1))
+		p := math.Exp(float64(i)*math.Log(lambda) - lambda - lgamma)
+		rate += p * math.Pow(1-math.Pow(1-1.0/64, float64(i)), blockWords)
+	}
+	return rate
+}
+
+func (bbf *BlockedBloomfilter) Add(val string) {
+	bbf.AddBytes([]byte(val))
+}
+
+func (bbf *BlockedBloomfilter) AddBytes(val []byte) {
+	block, mask := bbf.locate(val)
+	bbf.l.Lock()
+	defer bbf.l.Unlock()
+	for i := range mask {
+		block[i] |= mask[i]
+	}
+}
+
+func (bbf *BlockedBloomfilter) Test(val string) bool {
+	return bbf.TestBytes([]byte(val))
+}
+
+func (bbf *BlockedBloomfilter) TestBytes(val []byte) bool {
+	block, mask := bbf.locate(val)
+	bbf.l.RLock()
+	defer bbf.l.RUnlock()
+	var missing uint64
+	for i := range mask {
+		missing |= mask[i] &^ block[i]
+	}
+	return missing == 0
+}
+
+// TestAndAdd adds val and reports whether it was already present, in one locked pass.
+func (bbf *BlockedBloomfilter) TestAndAdd(val string) bool {
+	return bbf.TestAndAddBytes([]byte(val))
+}
+
+func (bbf *BlockedBloomfilter) TestAndAddBytes(val []byte) bool {
+	block, mask := bbf.locate(val)
+	bbf.l.Lock()
+	defer bbf.l.Unlock()
+	var missing uint64
+	for i := range mask {
+		missing |= mask[i] &^ block[i]
+		block[i] |= mask[i]
+	}
+	return missing == 0
+}
+
+// Blocks returns the number of 64-byte blocks.
+func (bbf *BlockedBloomfilter) Blocks() uint64 {
+	return bbf.blocks
+}
+
+func (bbf *BlockedBloomfilter) Data() []byte {
+	bbf.l.RLock()
+	defer bbf.l.RUnlock()
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.LittleEndian, bbf.blocks)
+	binary.Write(buf, binary.LittleEndian, bbf.words)
+	return buf.Bytes()
+}
+
+// locate returns the block of val and the mask of one bit per word it sets there.
+// The block comes from h1 mapped onto [0, blocks) by multiplication, the bits from h2,
+// so the two are independent.
+func (bbf *BlockedBloomfilter) locate(val []byte) ([]uint64, [blockWords]uint64) {
+	h1, h2 := hash.Murmur3Sum128(val, 0)
+	index, _ := bits.Mul64(h1, bbf.blocks)
+	var mask [blockWords]uint64
+	for i, salt := range blockSalts {
+		mask[i] = 1 << (uint32(h2) * salt >> 26)
+	}
+	start := index * blockWords
+	return bbf.words[start : start+blockWords : start+blockWords], mask
+}
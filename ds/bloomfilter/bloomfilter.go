@@ -1,8 +1,7 @@
 package bloomfilter
 
 import (
-	"bytes"
-	"encoding/binary"
+	"errors"
 	"goalds/al/hash"
 	"goalds/ds/bitmap"
 	"goalds/utils/locker"
@@ -12,10 +11,28 @@ import (
 
 const salt = "goalds"
 
+// HashAlgorithm selects how a Bloomfilter derives the k bit positions of a value.
+type HashAlgorithm uint8
+
+const (
+	// HashMurmur3 derives all positions from one MurmurHash3 x64 128 with
+	// Kirsch-Mitzenmacher double hashing: h1 + i*h2. It is the default.
+	HashMurmur3 HashAlgorithm = iota
+	// HashSHA512 is the original scheme, repeated SHA-512 of the salted value.
+	// NewFromData loads filters serialized before HashMurmur3 became the default with it.
+	HashSHA512
+)
+
 var defaultLocker locker.FakeLocker
 
+var ErrIncompatible = errors.New("bloomfilter: filters differ in m, k or hash algorithm")
+
 type Options struct {
-	locker locker.Locker
+	locker       locker.Locker
+	hash         HashAlgorithm
+	counterWidth uint8
+	growth       uint64
+	tightening   float64
 }
 
 type Option func(opt *Options)
@@ -26,16 +43,24 @@ func WithGoroutineSafe() Option {
 	}
 }
 
+func WithHashAlgorithm(hash HashAlgorithm) Option {
+	return func(opt *Options) {
+		opt.hash = hash
+	}
+}
+
 type Bloomfilter struct {
 	m uint64
 	k uint64
 	b *bitmap.Bitmap
 	l locker.Locker
+	h HashAlgorithm
 }
 
 func New(m uint64, k uint64, opts ...Option) *Bloomfilter {
 	opt := &Options{
 		locker: defaultLocker,
+		hash:   HashMurmur3,
 	}
 	for _, o := range opts {
 		o(opt)
@@ -45,6 +70,7 @@ func New(m uint64, k uint64, opts ...Option) *Bloomfilter {
 		k: k,
 		b: bitmap.New(m),
 		l: opt.locker,
+		h: opt.hash,
 	}
 }
 
@@ -56,20 +82,25 @@ func NewWithEstimates(n uint64, fp float64, opts ...Option) *Bloomfilter {
 	return New(m, k, opts...)
 }
 
+// NewFromData restores a filter from Data() or MarshalBinary(), including data written by
+// Data() before HashMurmur3 existed. It returns nil if data is truncated or corrupted; use
+// UnmarshalBinary to get the reason. The data records the hash algorithm, so
+// WithHashAlgorithm has no effect.
 func NewFromData(data []byte, opts ...Option) *Bloomfilter {
 	opt := &Options{
 		locker: defaultLocker,
+		hash:   HashMurmur3,
 	}
 	for _, o := range opts {
 		o(opt)
 	}
 	b := &Bloomfilter{
 		l: opt.locker,
+		h: opt.hash,
+	}
+	if err := b.UnmarshalBinary(data); err != nil {
+		return nil
 	}
-	reader := bytes.NewReader(data)
-	binary.Read(reader, binary.LittleEndian, &b.m)
-	binary.Read(reader, binary.LittleEndian, &b.k)
-	b.b = bitmap.NewFromBits(data[16:])
 	return b
 }
 
@@ -80,32 +111,137 @@ func EstimateParameters(n uint64, fp float64) (uint64, uint64) {
 }
 
 func (bf *Bloomfilter) Add(val string) {
+	bf.AddBytes([]byte(val))
+}
+
+func (bf *Bloomfilter) AddBytes(val []byte) {
 	bf.l.Lock()
 	defer bf.l.Unlock()
-	hashs := hash.GetHashInts([]byte(salt+val), int(bf.k))
-	for i := uint64(0); i < bf.k; i++ {
-		bf.b.Set(hashs[i] % bf.m)
-	}
+	bf.locations(val, bf.b.Set)
 }
 
 func (bf *Bloomfilter) Test(val string) bool {
+	return bf.TestBytes([]byte(val))
+}
+
+func (bf *Bloomfilter) TestBytes(val []byte) bool {
 	bf.l.RLock()
 	defer bf.l.RUnlock()
-	hashs := hash.GetHashInts([]byte(salt+val), int(bf.k))
-	for i := uint64(0); i < bf.k; i++ {
-		if !bf.b.IsSet(hashs[i] % bf.m) {
-			return false
+	return bf.locations(val, bf.b.IsSet)
+}
+
+// TestAndAdd adds val and reports whether it was already present, in one locked pass.
+func (bf *Bloomfilter) TestAndAdd(val string) bool {
+	return bf.TestAndAddBytes([]byte(val))
+}
+
+func (bf *Bloomfilter) TestAndAddBytes(val []byte) bool {
+	bf.l.Lock()
+	defer bf.l.Unlock()
+	present := true
+	bf.locations(val, func(pos uint64) bool {
+		if !bf.b.IsSet(pos) {
+			present = false
+			bf.b.Set(pos)
 		}
+		return true
+	})
+	return present
+}
+
+// Union adds every value of other to bf. Both filters must have the same m, k and hash algorithm.
+func (bf *Bloomfilter) Union(other *Bloomfilter) error {
+	return bf.combine(other, (*bitmap.Bitmap).Or)
+}
+
+// Intersect keeps in bf only the bits also set in other. The result may test positive for
+// more values than the true intersection, and its estimated count is less accurate.
+// Both filters must have the same m, k and hash algorithm.
+func (bf *Bloomfilter) Intersect(other *Bloomfilter) error {
+	return bf.combine(other, (*bitmap.Bitmap).And)
+}
+
+// FillRatio returns the fraction of the m bits that are set.
+func (bf *Bloomfilter) FillRatio() float64 {
+	bf.l.RLock()
+	defer bf.l.RUnlock()
+	return float64(bf.b.Count()) / float64(bf.m)
+}
+
+// EstimatedCount estimates how many distinct values were added from the number of set bits X,
+// -(m/k) * ln(1 - X/m) (Swamidass and Baldi, 2007). A full filter returns math.MaxUint64.
+func (bf *Bloomfilter) EstimatedCount() uint64 {
+	bf.l.RLock()
+	defer bf.l.RUnlock()
+	x := bf.b.Count()
+	if x >= bf.m {
+		return math.MaxUint64
 	}
-	return true
+	m, k := float64(bf.m), float64(bf.k)
+	return uint64(math.Round(-m / k * math.Log(1-float64(x)/m)))
+}
+
+// EstimatedFalsePositiveRate returns the probability that a value never added tests positive
+// given the current fill ratio, FillRatio()^k.
+func (bf *Bloomfilter) EstimatedFalsePositiveRate() float64 {
+	return math.Pow(bf.FillRatio(), float64(bf.k))
 }
 
+// Data returns the filter in the legacy layout if it uses HashSHA512, as it always did, and
+// in the versioned layout of MarshalBinary otherwise, so that NewFromData restores either.
+//
+// Deprecated: use MarshalBinary, which always records the hash algorithm and a checksum.
 func (bf *Bloomfilter) Data() []byte {
 	bf.l.RLock()
 	defer bf.l.RUnlock()
-	buf := new(bytes.Buffer)
-	binary.Write(buf, binary.LittleEndian, bf.m)
-	binary.Write(buf, binary.LittleEndian, bf.k)
-	buf.Write(bf.b.Data())
-	return buf.Bytes()
+	return encode(bf.h, bf.m, bf.k, bf.b.Data())
+}
+
+func (bf *Bloomfilter) locations(data []byte, visitor func(pos uint64) bool) bool {
+	return locations(bf.h, bf.m, bf.k, data, visitor)
+}
+
+// locations calls visitor with each of the k positions in [0, m) of data
+// until visitor returns false, and reports whether it never did.
+func locations(alg HashAlgorithm, m, k uint64, data []byte, visitor func(pos uint64) bool) bool {
+	if alg == HashSHA512 {
+		hashs := hash.GetHashInts(append([]byte(salt), data...), int(k))
+		for i := uint64(0); i < k; i++ {
+			if !visitor(hashs[i] % m) {
+				return false
+			}
+		}
+		return true
+	}
+	h1, h2 := hash.Murmur3Sum128(data, 0)
+	return doubleHash(m, k, h1, h2, visitor)
+}
+
+// doubleHash calls visitor with the k positions (h1 + i*h2) mod m until visitor returns false.
+func doubleHash(m, k, h1, h2 uint64, visitor func(pos uint64) bool) bool {
+	for i := uint64(0); i < k; i++ {
+		if !visitor((h1 + i*h2) % m) {
+			return false
+		}
+	}
+	return true
+}
+
+func (bf *Bloomfilter) combine(other *Bloomfilter, op func(b, other *bitmap.Bitmap)) error {
+	if bf.m != other.m || bf.k != other.k || bf.h != other.h {
+		return ErrIncompatible
+	}
+	if bf == other {
+		return nil
+	}
+	// copy other first, so that bf.Union(other) and other.Union(bf) never wait on each other
+	other.l.RLock()
+	bits := bitmap.New(other.m)
+	bits.Or(other.b)
+	other.l.RUnlock()
+
+	bf.l.Lock()
+	defer bf.l.Unlock()
+	op(bf.b, bits)
+	return nil
 }
@@ -1,6 +1,18 @@
 package bloomfilter
 
 import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"goalds/al/hash"
+	"goalds/ds/bitmap"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -15,3 +27,605 @@ func TestBloomfilter(t *testing.T) {
 	b := NewFromData(a.Data(), WithGoroutineSafe())
 	assert.True(t, b.Test("hello"))
 }
+
+func TestFalsePositiveRate(t *testing.T) {
+	for _, h := range []HashAlgorithm{HashMurmur3, HashSHA512} {
+		bf := NewWithEstimates(10000, 0.01, WithHashAlgorithm(h))
+		for i := 0; i < 10000; i++ {
+			bf.Add(strconv.Itoa(i))
+		}
+		for i := 0; i < 10000; i++ {
+			assert.True(t, bf.Test(strconv.Itoa(i)))
+		}
+		fp := 0
+		for i := 10000; i < 20000; i++ {
+			if bf.Test(strconv.Itoa(i)) {
+				fp++
+			}
+		}
+		assert.Less(t, fp, 200)
+	}
+}
+
+func TestLegacySHA512(t *testing.T) {
+	m, k := EstimateParameters(100, 0.01)
+	// the bits the original implementation sets for "hello"
+	expected := bitmap.New(m)
+	hashs := hash.GetHashInts([]byte(salt+"hello"), int(k))
+	for i := uint64(0); i < k; i++ {
+		expected.Set(hashs[i] % m)
+	}
+	// the bytes Data() wrote before HashMurmur3 existed: m, k and the bits
+	legacy := binary.LittleEndian.AppendUint64(nil, m)
+	legacy = binary.LittleEndian.AppendUint64(legacy, k)
+	legacy = append(legacy, expected.Data()...)
+
+	// they load as HashSHA512 without options, whatever the options say
+	for _, bf := range []*Bloomfilter{NewFromData(legacy), NewFromData(legacy, WithHashAlgorithm(HashMurmur3))} {
+		assert.Equal(t, HashSHA512, bf.h)
+		assert.True(t, bf.Test("hello"))
+		assert.False(t, bf.Test("world"))
+	}
+	assert.Equal(t, legacy, NewConcurrentFromData(legacy).Data())
+
+	// a HashSHA512 filter still writes exactly those bytes
+	bf := New(m, k, WithHashAlgorithm(HashSHA512))
+	bf.Add("hello")
+	assert.Equal(t, legacy, bf.Data())
+
+	// a HashMurmur3 filter writes the versioned layout, which records the algorithm
+	bf = New(m, k)
+	bf.Add("hello")
+	marshaled, _ := bf.MarshalBinary()
+	assert.Equal(t, marshaled, bf.Data())
+	restored := NewFromData(bf.Data())
+	assert.Equal(t, HashMurmur3, restored.h)
+	assert.True(t, restored.Test("hello"))
+	assert.Equal(t, bf.Data(), NewConcurrentFromData(bf.Data()).Data())
+}
+
+func TestBytes(t *testing.T) {
+	bf := NewWithEstimates(1024, 0.01, WithGoroutineSafe())
+	bf.AddBytes([]byte("hello"))
+	assert.True(t, bf.TestBytes([]byte("hello")))
+	assert.True(t, bf.Test("hello"))
+	bf.Add("world")
+	assert.True(t, bf.TestBytes([]byte("world")))
+
+	assert.False(t, bf.TestAndAdd("foo"))
+	assert.True(t, bf.TestAndAdd("foo"))
+	assert.False(t, bf.TestAndAddBytes([]byte("bar")))
+	assert.True(t, bf.Test("bar"))
+}
+
+func TestTyped(t *testing.T) {
+	ints := NewTypedWithEstimates[int64](1000, 0.01, IntegerHasher[int64], WithGoroutineSafe())
+	for i := int64(0); i < 1000; i++ {
+		assert.False(t, ints.TestAndAdd(i*7))
+	}
+	fp := 0
+	for i := int64(0); i < 1000; i++ {
+		assert.True(t, ints.Test(i*7))
+		if ints.Test(i*7 + 1) {
+			fp++
+		}
+	}
+	assert.Less(t, fp, 30)
+
+	restored := NewTypedFromData[int64](ints.Data(), IntegerHasher[int64])
+	assert.True(t, restored.Test(7))
+
+	type point struct{ x, y int }
+	points := NewTyped[point](1024, 4, func(p point) (uint64, uint64) {
+		return IntegerHasher(p.x*31 + p.y)
+	})
+	points.Add(point{1, 2})
+	assert.True(t, points.Test(point{1, 2}))
+	assert.False(t, points.Test(point{2, 1}))
+
+	strs := NewTyped[string](1024, 4, StringHasher)
+	strs.Add("hello")
+	assert.True(t, strs.Test("hello"))
+	bs := NewTypedFromData[[]byte](strs.Data(), BytesHasher)
+	assert.True(t, bs.Test([]byte("hello")))
+	plain := NewFromData(strs.Data())
+	assert.True(t, plain.Test("hello"))
+
+	data := strs.Data()
+	for i := 0; i < len(data); i++ {
+		assert.Nil(t, NewTypedFromData(data[:i], StringHasher), i)
+	}
+	assert.Nil(t, NewTypedFromData([]byte{1, 2, 3}, StringHasher))
+}
+
+func TestCounting(t *testing.T) {
+	for _, width := range []uint8{2, 4, 8, 16, 32} {
+		cbf := NewCountingWithEstimates(1000, 0.01, WithCounterWidth(width), WithGoroutineSafe())
+		assert.False(t, cbf.Test("hello"))
+		assert.False(t, cbf.Remove("hello"))
+		cbf.Add("hello")
+		cbf.AddBytes([]byte("hello"))
+		assert.True(t, cbf.Test("hello"))
+		assert.Equal(t, uint64(2), cbf.Count("hello"))
+		assert.Equal(t, uint64(0), cbf.Count("world"))
+
+		assert.True(t, cbf.Remove("hello"))
+		assert.True(t, cbf.TestBytes([]byte("hello")))
+		assert.True(t, cbf.RemoveBytes([]byte("hello")))
+		assert.False(t, cbf.Test("hello"))
+
+		for i := 0; i < 1000; i++ {
+			cbf.Add(strconv.Itoa(i))
+		}
+		for i := 0; i < 1000; i += 2 {
+			assert.True(t, cbf.Remove(strconv.Itoa(i)))
+		}
+		fp := 0
+		for i := 0; i < 1000; i++ {
+			if i%2 == 1 {
+				assert.True(t, cbf.Test(strconv.Itoa(i)))
+			} else if cbf.Test(strconv.Itoa(i)) {
+				fp++
+			}
+		}
+		assert.Less(t, fp, 20)
+
+		restored := NewCountingFromData(cbf.Data())
+		for i := 1; i < 1000; i += 2 {
+			assert.True(t, restored.Test(strconv.Itoa(i)))
+		}
+	}
+}
+
+func TestCountingCorruptedData(t *testing.T) {
+	cbf := NewCountingWithEstimates(100, 0.01)
+	cbf.Add("hello")
+	data := cbf.Data()
+	for i := 0; i < len(data); i++ {
+		assert.Nil(t, NewCountingFromData(data[:i]), i)
+	}
+	assert.Nil(t, NewCountingFromData(append(data, 0)))
+	assert.True(t, NewCountingFromData(data).Test("hello"))
+
+	corrupt := func(offset int, value uint64) []byte {
+		bad := append([]byte{}, data...)
+		binary.LittleEndian.PutUint64(bad[offset:], value)
+		return bad
+	}
+	assert.Nil(t, NewCountingFromData(corrupt(0, 0)))
+	assert.Nil(t, NewCountingFromData(corrupt(0, math.MaxUint64)))
+	assert.Nil(t, NewCountingFromData(corrupt(8, 0)))
+	assert.Nil(t, NewCountingFromData(corrupt(16, 0)))
+	assert.Nil(t, NewCountingFromData(corrupt(16, 3)))
+	assert.Nil(t, NewCountingFromData(corrupt(16, 64)))
+	assert.Nil(t, NewCountingFromData(corrupt(24, 2)))
+
+	// the data records the hash algorithm
+	sha := NewCounting(1000, 5, WithHashAlgorithm(HashSHA512))
+	sha.Add("hello")
+	restored := NewCountingFromData(sha.Data())
+	assert.Equal(t, HashSHA512, restored.h)
+	assert.True(t, restored.Test("hello"))
+	assert.Equal(t, sha.Data(), restored.Data())
+}
+
+func TestCountingSaturation(t *testing.T) {
+	cbf := NewCounting(64, 3, WithCounterWidth(2))
+	for i := 0; i < 5; i++ {
+		cbf.Add("hello")
+	}
+	assert.Equal(t, uint64(3), cbf.Count("hello"))
+	for i := 0; i < 5; i++ {
+		assert.True(t, cbf.Remove("hello"))
+	}
+	// saturated counters are sticky, so the value can never be falsely removed
+	assert.True(t, cbf.Test("hello"))
+
+	assert.Panics(t, func() { NewCounting(64, 3, WithCounterWidth(3)) })
+}
+
+func TestScalable(t *testing.T) {
+	sbf := NewScalable(100, 0.01, WithGoroutineSafe())
+	assert.Equal(t, 1, sbf.Stages())
+	assert.Equal(t, 0.0, sbf.FalsePositiveRate())
+	assert.False(t, sbf.TestAndAdd("hello"))
+	assert.True(t, sbf.TestAndAdd("hello"))
+	sbf.Add("hello")
+	assert.Equal(t, uint64(1), sbf.Count())
+
+	for i := 0; i < 5000; i++ {
+		sbf.Add(strconv.Itoa(i))
+	}
+	assert.True(t, sbf.Stages() > 5)
+	assert.True(t, sbf.Count() > 4700)
+	for i := 0; i < 5000; i++ {
+		assert.True(t, sbf.Test(strconv.Itoa(i)))
+	}
+	fp := 0
+	for i := 5000; i < 15000; i++ {
+		if sbf.TestBytes([]byte(strconv.Itoa(i))) {
+			fp++
+		}
+	}
+	// the compound rate is bounded by fp
+	assert.Less(t, sbf.FalsePositiveRate(), 0.01)
+	assert.Less(t, float64(fp)/10000, 0.01)
+
+	restored := NewScalableFromData(sbf.Data())
+	assert.Equal(t, sbf.Stages(), restored.Stages())
+	assert.Equal(t, sbf.Count(), restored.Count())
+	assert.Equal(t, sbf.FalsePositiveRate(), restored.FalsePositiveRate())
+	for i := 0; i < 5000; i++ {
+		assert.True(t, restored.Test(strconv.Itoa(i)))
+	}
+	restored.Add("more")
+	assert.True(t, restored.Test("more"))
+
+	// however far the filter grows past its initial capacity
+	for _, times := range []int{10, 100} {
+		grown := NewScalable(1000, 0.01)
+		for i := 0; i < 1000*times; i++ {
+			grown.Add(strconv.Itoa(i))
+		}
+		fp := 0
+		for i := 0; i < 100000; i++ {
+			if grown.Test("x" + strconv.Itoa(i)) {
+				fp++
+			}
+		}
+		assert.Less(t, grown.FalsePositiveRate(), 0.01)
+		assert.Less(t, float64(fp)/100000, 0.01)
+	}
+
+	small := NewScalable(10, 0.01, WithGrowth(4), WithTightening(0.5))
+	for i := 0; i < 60; i++ {
+		small.Add(strconv.Itoa(i))
+	}
+	assert.Equal(t, 3, small.Stages())
+
+	assert.Panics(t, func() { NewScalable(10, 0.01, WithTightening(1)) })
+	assert.Panics(t, func() { NewScalable(10, 0.01, WithGrowth(0)) })
+}
+
+func TestScalableCorruptedData(t *testing.T) {
+	sbf := NewScalable(10, 0.01, WithHashAlgorithm(HashSHA512))
+	for i := 0; i < 50; i++ {
+		sbf.Add(strconv.Itoa(i))
+	}
+	data := sbf.Data()
+	for i := 0; i < len(data); i++ {
+		assert.Nil(t, NewScalableFromData(data[:i]), i)
+	}
+	assert.Nil(t, NewScalableFromData(append(data, 0)))
+
+	// the stages record their hash algorithm, so no option is needed
+	restored := NewScalableFromData(data)
+	for i := 0; i < 50; i++ {
+		assert.True(t, restored.Test(strconv.Itoa(i)))
+	}
+	assert.Equal(t, HashSHA512, restored.h)
+
+	corrupt := func(offset int, value uint64) []byte {
+		bad := append([]byte{}, data...)
+		binary.LittleEndian.PutUint64(bad[offset:], value)
+		return bad
+	}
+	assert.Nil(t, NewScalableFromData(corrupt(0, 0)))
+	assert.Nil(t, NewScalableFromData(corrupt(8, math.Float64bits(2))))
+	assert.Nil(t, NewScalableFromData(corrupt(16, 0)))
+	assert.Nil(t, NewScalableFromData(corrupt(24, math.Float64bits(1))))
+	// no stages
+	assert.Nil(t, NewScalableFromData(corrupt(32, 0)[:40]))
+	// a stage size beyond the input
+	assert.Nil(t, NewScalableFromData(corrupt(48, math.MaxUint64)))
+	// a stage that is not a valid filter
+	assert.Nil(t, NewScalableFromData(corrupt(56, 0)))
+}
+
+func TestBlocked(t *testing.T) {
+	bbf := NewBlockedWithEstimates(10000, 0.01, WithGoroutineSafe())
+	assert.False(t, bbf.Test("hello"))
+	assert.False(t, bbf.TestAndAdd("hello"))
+	assert.True(t, bbf.TestAndAddBytes([]byte("hello")))
+
+	for i := 0; i < 10000; i++ {
+		bbf.Add(strconv.Itoa(i))
+	}
+	for i := 0; i < 10000; i++ {
+		assert.True(t, bbf.TestBytes([]byte(strconv.Itoa(i))))
+	}
+	fp := 0
+	for i := 10000; i < 110000; i++ {
+		if bbf.Test(strconv.Itoa(i)) {
+			fp++
+		}
+	}
+	assert.InDelta(t, 0.01, float64(fp)/100000, 0.003)
+
+	restored := NewBlockedFromData(bbf.Data())
+	assert.Equal(t, bbf.Blocks(), restored.Blocks())
+	for i := 0; i < 10000; i++ {
+		assert.True(t, restored.Test(strconv.Itoa(i)))
+	}
+	assert.Nil(t, NewBlockedFromData(bbf.Data()[:100]))
+}
+
+func TestBlockedEstimates(t *testing.T) {
+	for _, fp := range []float64{0.1, 0.01, 0.001} {
+		blocks := EstimateBlockedParameters(100000, fp)
+		assert.LessOrEqual(t, BlockedFalsePositiveRate(100000, blocks), fp)
+		assert.Greater(t, BlockedFalsePositiveRate(100000, blocks-1), fp)
+		// a blocked filter needs more memory than a standard one for the same rate
+		m, _ := EstimateParameters(100000, fp)
+		assert.Greater(t, blocks*512, m)
+	}
+	assert.Equal(t, 0.0, BlockedFalsePositiveRate(0, 10))
+}
+
+func TestConcurrent(t *testing.T) {
+	cbf := NewConcurrentWithEstimates(10000, 0.01)
+	assert.False(t, cbf.TestAndAdd("hello"))
+	assert.True(t, cbf.TestAndAdd("hello"))
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := g; i < 10000; i += 8 {
+				cbf.Add(strconv.Itoa(i))
+				// a value is visible as soon as its own Add returns
+				assert.True(t, cbf.Test(strconv.Itoa(i)))
+				cbf.TestBytes([]byte(strconv.Itoa(i + 1)))
+			}
+		}(g)
+	}
+	wg.Wait()
+	for i := 0; i < 10000; i++ {
+		assert.True(t, cbf.Test(strconv.Itoa(i)))
+	}
+
+	// the bits match a Bloomfilter fed the same values
+	bf := NewWithEstimates(10000, 0.01)
+	bf.Add("hello")
+	for i := 0; i < 10000; i++ {
+		bf.Add(strconv.Itoa(i))
+	}
+	assert.Equal(t, bf.Data(), cbf.Data())
+	restored := NewConcurrentFromData(bf.Data())
+	assert.Equal(t, cbf.Data(), restored.Data())
+	assert.Nil(t, NewConcurrentFromData(bf.Data()[:10]))
+}
+
+func TestConcurrentTestAndAdd(t *testing.T) {
+	cbf := NewConcurrent(1<<16, 4)
+	var added atomic.Int64
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				if !cbf.TestAndAdd(strconv.Itoa(i)) {
+					added.Add(1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	// each bit is set by exactly one goroutine, so a value is reported absent at most k times,
+	// and at least once unless it is a false positive
+	assert.GreaterOrEqual(t, added.Load(), int64(990))
+	assert.LessOrEqual(t, added.Load(), int64(4000))
+}
+
+func TestMapped(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "filter")
+	m, k := EstimateParameters(10000, 0.01)
+	mbf, err := CreateMapped(path, m, k, WithHashAlgorithm(HashSHA512), WithGoroutineSafe())
+	assert.Nil(t, err)
+	assert.False(t, mbf.TestAndAdd("hello"))
+	for i := 0; i < 10000; i++ {
+		mbf.Add(strconv.Itoa(i))
+	}
+	assert.Nil(t, mbf.Sync())
+
+	// the bits match a Bloomfilter fed the same values
+	bf := New(m, k, WithHashAlgorithm(HashSHA512))
+	bf.Add("hello")
+	for i := 0; i < 10000; i++ {
+		bf.Add(strconv.Itoa(i))
+	}
+	assert.Equal(t, bf.Data(), mbf.Data())
+	assert.Equal(t, bf.FillRatio(), mbf.FillRatio())
+	assert.Nil(t, mbf.Close())
+
+	// reopening reads the parameters and the hash algorithm from the header
+	mbf, err = OpenMapped(path)
+	assert.Nil(t, err)
+	assert.True(t, mbf.Test("hello"))
+	mbf.AddBytes([]byte("world"))
+	assert.Nil(t, mbf.Close())
+
+	ro, err := OpenMappedReadOnly(path)
+	assert.Nil(t, err)
+	assert.True(t, ro.TestBytes([]byte("world")))
+	for i := 0; i < 10000; i++ {
+		assert.True(t, ro.Test(strconv.Itoa(i)))
+	}
+	assert.Panics(t, func() { ro.Add("more") })
+	assert.Nil(t, ro.Sync())
+	assert.Nil(t, ro.Close())
+
+	data, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, 64+int((m+7)/8), len(data))
+}
+
+func TestMappedInvalidFile(t *testing.T) {
+	dir := t.TempDir()
+	_, err := OpenMapped(filepath.Join(dir, "missing"))
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+
+	path := filepath.Join(dir, "filter")
+	assert.Nil(t, os.WriteFile(path, make([]byte, 100), 0644))
+	_, err = OpenMapped(path)
+	assert.Equal(t, ErrInvalidMappedFile, err)
+
+	mbf, err := CreateMapped(path, 1000, 3)
+	assert.Nil(t, err)
+	assert.Nil(t, mbf.Close())
+	assert.Nil(t, os.Truncate(path, 64+100))
+	_, err = OpenMappedReadOnly(path)
+	assert.ErrorContains(t, err, "invalid parameters")
+
+	_, err = CreateMapped(path, 0, 3)
+	assert.NotNil(t, err)
+}
+
+func TestUnionAndIntersect(t *testing.T) {
+	a := NewWithEstimates(2000, 0.01, WithGoroutineSafe())
+	b := NewWithEstimates(2000, 0.01)
+	for i := 0; i < 1000; i++ {
+		a.Add(strconv.Itoa(i))
+		b.Add(strconv.Itoa(i + 500))
+	}
+
+	union := NewFromData(a.Data())
+	assert.Nil(t, union.Union(b))
+	for i := 0; i < 1500; i++ {
+		assert.True(t, union.Test(strconv.Itoa(i)))
+	}
+	assert.InEpsilon(t, 1500, float64(union.EstimatedCount()), 0.05)
+
+	intersection := NewFromData(a.Data())
+	assert.Nil(t, intersection.Intersect(b))
+	for i := 500; i < 1000; i++ {
+		assert.True(t, intersection.Test(strconv.Itoa(i)))
+	}
+	assert.InEpsilon(t, 500, float64(intersection.EstimatedCount()), 0.2)
+
+	assert.Equal(t, ErrIncompatible, a.Union(NewWithEstimates(1000, 0.01)))
+	assert.Equal(t, ErrIncompatible, a.Intersect(New(a.m, a.k, WithHashAlgorithm(HashSHA512))))
+	assert.Nil(t, a.Union(a))
+
+	// unions in both directions at once do not deadlock
+	x := NewWithEstimates(100, 0.01, WithGoroutineSafe())
+	y := NewWithEstimates(100, 0.01, WithGoroutineSafe())
+	x.Add("x")
+	y.Add("y")
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			x.Union(y)
+		}()
+		go func() {
+			defer wg.Done()
+			y.Union(x)
+		}()
+	}
+	wg.Wait()
+	assert.True(t, x.Test("y"))
+	assert.True(t, y.Test("x"))
+}
+
+func TestEstimates(t *testing.T) {
+	bf := NewWithEstimates(1000, 0.01)
+	assert.Equal(t, 0.0, bf.FillRatio())
+	assert.Equal(t, uint64(0), bf.EstimatedCount())
+	assert.Equal(t, 0.0, bf.EstimatedFalsePositiveRate())
+
+	for i := 0; i < 1000; i++ {
+		bf.Add(strconv.Itoa(i))
+	}
+	// a filter filled to capacity is about half full and has the requested rate
+	assert.InDelta(t, 0.5, bf.FillRatio(), 0.05)
+	assert.InEpsilon(t, 1000, float64(bf.EstimatedCount()), 0.05)
+	assert.InDelta(t, 0.01, bf.EstimatedFalsePositiveRate(), 0.005)
+
+	full := New(8, 1)
+	for i := 0; i < 1000; i++ {
+		full.Add(strconv.Itoa(i))
+	}
+	assert.Equal(t, 1.0, full.FillRatio())
+	assert.Equal(t, uint64(math.MaxUint64), full.EstimatedCount())
+}
+
+func TestSerialization(t *testing.T) {
+	bf := NewWithEstimates(1000, 0.01, WithHashAlgorithm(HashSHA512))
+	for i := 0; i < 500; i++ {
+		bf.Add(strconv.Itoa(i))
+	}
+	buf := new(bytes.Buffer)
+	n, err := bf.WriteTo(buf)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(buf.Len()), n)
+	data := buf.Bytes()
+
+	// the versioned layout records the hash algorithm, so no option is needed
+	restored := New(8, 1, WithGoroutineSafe())
+	n, err = restored.ReadFrom(bytes.NewReader(data))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(len(data)), n)
+	assert.Equal(t, bf.Data(), restored.Data())
+	for i := 0; i < 500; i++ {
+		assert.True(t, restored.Test(strconv.Itoa(i)))
+	}
+
+	marshaled, err := bf.MarshalBinary()
+	assert.Nil(t, err)
+	assert.Equal(t, data, marshaled)
+	var zero Bloomfilter
+	assert.Nil(t, zero.UnmarshalBinary(marshaled))
+	assert.True(t, zero.Test("1"))
+	assert.NotNil(t, NewFromData(marshaled))
+	assert.True(t, NewFromData(marshaled).Test("1"))
+
+	// legacy Data() layout
+	legacy := New(1000, 5, WithHashAlgorithm(HashSHA512))
+	legacy.Add("hello")
+	var fromLegacy Bloomfilter
+	assert.Nil(t, fromLegacy.UnmarshalBinary(legacy.Data()))
+	assert.True(t, fromLegacy.Test("hello"))
+	restored = New(8, 1)
+	_, err = restored.ReadFrom(bytes.NewReader(legacy.Data()))
+	assert.Nil(t, err)
+	assert.Equal(t, HashSHA512, restored.h)
+	assert.True(t, restored.Test("hello"))
+}
+
+func TestCorruptedData(t *testing.T) {
+	bf := NewWithEstimates(100, 0.01)
+	bf.Add("hello")
+	data, _ := bf.MarshalBinary()
+
+	for i := 0; i < len(data); i++ {
+		var b Bloomfilter
+		assert.NotNil(t, b.UnmarshalBinary(data[:i]))
+		assert.Nil(t, NewFromData(data[:i]))
+		assert.Nil(t, NewFromData(bf.Data()[:i*len(bf.Data())/len(data)]))
+	}
+
+	corrupted := append([]byte{}, data...)
+	corrupted[30] ^= 1
+	var b Bloomfilter
+	assert.Equal(t, ErrChecksumMismatch, b.UnmarshalBinary(corrupted))
+
+	corrupted = append([]byte{}, data...)
+	corrupted[4] = 9
+	assert.ErrorContains(t, b.UnmarshalBinary(corrupted), "version")
+
+	corrupted = append([]byte{}, data...)
+	corrupted[6] = 9
+	assert.ErrorContains(t, b.UnmarshalBinary(corrupted), "hash algorithm")
+
+	assert.Equal(t, ErrTrailingData, b.UnmarshalBinary(append(data, 0)))
+	assert.ErrorContains(t, b.UnmarshalBinary(make([]byte, 16)), "invalid parameters")
+
+	// a huge m in a short input must fail without allocating m bits
+	huge := append([]byte{}, bf.Data()...)
+	binary.LittleEndian.PutUint64(huge, 1<<60)
+	assert.ErrorIs(t, b.UnmarshalBinary(huge), io.ErrUnexpectedEOF)
+}
@@ -0,0 +1,121 @@
+package bloomfilter
+
+import (
+	"encoding/binary"
+	"sync/atomic"
+)
+
+// ConcurrentBloomfilter is a Bloom filter that Add and Test may use from many goroutines
+// without a lock. Since bits only ever go from 0 to 1, each one is set with a CAS loop
+// on its uint64 word, and concurrent Adds of different values never wait for each other.
+// A Test running alongside an Add of the same value may see only some of its bits, and
+// so report it absent; once Add returns, every later Test reports it present.
+// WithGoroutineSafe is ignored, it is always safe.
+type ConcurrentBloomfilter struct {
+	m     uint64
+	k     uint64
+	words []atomic.Uint64
+	h     HashAlgorithm
+}
+
+func NewConcurrent(m uint64, k uint64, opts ...Option) *ConcurrentBloomfilter {
+	opt := &Options{hash: HashMurmur3}
+	for _, o := range opts {
+		o(opt)
+	}
+	return &ConcurrentBloomfilter{
+		m:     m,
+		k:     k,
+		words: make([]atomic.Uint64, (m+63)/64),
+		h:     opt.hash,
+	}
+}
+
+// NewConcurrentWithEstimates creates a concurrent Bloom filter for n elements with the false positive rate fp.
+func NewConcurrentWithEstimates(n uint64, fp float64, opts ...Option) *ConcurrentBloomfilter {
+	m, k := EstimateParameters(n, fp)
+	return NewConcurrent(m, k, opts...)
+}
+
+// NewConcurrentFromData restores a filter from the data of a Bloomfilter or ConcurrentBloomfilter,
+// in any layout NewFromData accepts. It returns nil if data is truncated or corrupted.
+func NewConcurrentFromData(data []byte, opts ...Option) *ConcurrentBloomfilter {
+	bf := NewFromData(data, opts...)
+	if bf == nil {
+		return nil
+	}
+	cbf := NewConcurrent(bf.m, bf.k, WithHashAlgorithm(bf.h))
+	bits := bf.b.Data()
+	for i := range cbf.words {
+		var word [8]byte
+		copy(word[:], bits[i*8:])
+		cbf.words[i].Store(binary.LittleEndian.Uint64(word[:]))
+	}
+	return cbf
+}
+
+func (cbf *ConcurrentBloomfilter) Add(val string) {
+	cbf.AddBytes([]byte(val))
+}
+
+func (cbf *ConcurrentBloomfilter) AddBytes(val []byte) {
+	locations(cbf.h, cbf.m, cbf.k, val, func(pos uint64) bool {
+		cbf.set(pos)
+		return true
+	})
+}
+
+func (cbf *ConcurrentBloomfilter) Test(val string) bool {
+	return cbf.TestBytes([]byte(val))
+}
+
+func (cbf *ConcurrentBloomfilter) TestBytes(val []byte) bool {
+	return locations(cbf.h, cbf.m, cbf.k, val, cbf.isSet)
+}
+
+// TestAndAdd adds val and reports whether all its bits were already set. When the same
+// new value is added by several goroutines at once, more than one may report it absent.
+func (cbf *ConcurrentBloomfilter) TestAndAdd(val string) bool {
+	return cbf.TestAndAddBytes([]byte(val))
+}
+
+func (cbf *ConcurrentBloomfilter) TestAndAddBytes(val []byte) bool {
+	present := true
+	locations(cbf.h, cbf.m, cbf.k, val, func(pos uint64) bool {
+		if cbf.set(pos) {
+			present = false
+		}
+		return true
+	})
+	return present
+}
+
+// Data returns the filter in the layout of Bloomfilter.Data(), so NewFromData can load it
+// into a Bloomfilter. Bits set by concurrent Adds may or may not be included.
+func (cbf *ConcurrentBloomfilter) Data() []byte {
+	bits := make([]byte, 0, len(cbf.words)*8)
+	for i := range cbf.words {
+		bits = binary.LittleEndian.AppendUint64(bits, cbf.words[i].Load())
+	}
+	// a bitmap of m bits holds (m+7)/8 bytes
+	return encode(cbf.h, cbf.m, cbf.k, bits[:(cbf.m+7)/8])
+}
+
+// set sets the bit at pos and reports whether it was previously clear.
+func (cbf *ConcurrentBloomfilter) set(pos uint64) bool {
+	word := &cbf.words[pos/64]
+	mask := uint64(1) << (pos % 64)
+	for {
+		old := word.Load()
+		if old&mask != 0 {
+			return false
+		}
+		if word.CompareAndSwap(old, old|mask) {
+			return true
+		}
+	}
+}
+
+func (cbf *ConcurrentBloomfilter) isSet(pos uint64) bool {
+	return cbf.words[pos/64].Load()&(1<<(pos%64)) != 0
+}
@@ -0,0 +1,200 @@
+package bloomfilter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"goalds/utils/locker"
+)
+
+const defaultCounterWidth = 4
+
+// WithCounterWidth sets the bits per counter of a CountingBloomfilter, 4 by default.
+// It must be 2, 4, 8, 16 or 32 so that counters never straddle two words.
+func WithCounterWidth(width uint8) Option {
+	return func(opt *Options) {
+		opt.counterWidth = width
+	}
+}
+
+// CountingBloomfilter replaces every bit of a Bloomfilter with a small saturating counter,
+// which makes Remove possible. A counter that reached its maximum is never decremented
+// again, since its real value is unknown.
+type CountingBloomfilter struct {
+	m        uint64
+	k        uint64
+	width    uint64
+	counters []uint64
+	l        locker.Locker
+	h        HashAlgorithm
+}
+
+func NewCounting(m uint64, k uint64, opts ...Option) *CountingBloomfilter {
+	opt := countingOptions(opts)
+	cbf := &CountingBloomfilter{
+		m:     m,
+		k:     k,
+		width: uint64(opt.counterWidth),
+		l:     opt.locker,
+		h:     opt.hash,
+	}
+	cbf.counters = make([]uint64, (m+cbf.perWord()-1)/cbf.perWord())
+	return cbf
+}
+
+// NewCountingWithEstimates creates a counting Bloom filter for n elements with the false positive rate fp.
+func NewCountingWithEstimates(n uint64, fp float64, opts ...Option) *CountingBloomfilter {
+	m, k := EstimateParameters(n, fp)
+	return NewCounting(m, k, opts...)
+}
+
+// NewCountingFromData restores a filter from CountingBloomfilter.Data(). It returns nil if data
+// is truncated or invalid. WithCounterWidth and WithHashAlgorithm are ignored because the
+// data records the width and the hash algorithm.
+func NewCountingFromData(data []byte, opts ...Option) *CountingBloomfilter {
+	opt := countingOptions(opts)
+	cbf := &CountingBloomfilter{
+		l: opt.locker,
+		h: opt.hash,
+	}
+	reader := bytes.NewReader(data)
+	var h uint64
+	for _, field := range []*uint64{&cbf.m, &cbf.k, &cbf.width, &h} {
+		if binary.Read(reader, binary.LittleEndian, field) != nil {
+			return nil
+		}
+	}
+	if HashAlgorithm(h) != HashMurmur3 && HashAlgorithm(h) != HashSHA512 {
+		return nil
+	}
+	cbf.h = HashAlgorithm(h)
+	switch cbf.width {
+	case 2, 4, 8, 16, 32:
+	default:
+		return nil
+	}
+	if cbf.m == 0 || cbf.k == 0 {
+		return nil
+	}
+	// checked against the remaining input before allocating
+	words := (cbf.m-1)/cbf.perWord() + 1
+	if uint64(reader.Len())%8 != 0 || uint64(reader.Len())/8 != words {
+		return nil
+	}
+	cbf.counters = make([]uint64, words)
+	binary.Read(reader, binary.LittleEndian, cbf.counters)
+	return cbf
+}
+
+func countingOptions(opts []Option) *Options {
+	opt := &Options{
+		locker:       defaultLocker,
+		hash:         HashMurmur3,
+		counterWidth: defaultCounterWidth,
+	}
+	for _, o := range opts {
+		o(opt)
+	}
+	switch opt.counterWidth {
+	case 2, 4, 8, 16, 32:
+	default:
+		panic("bloomfilter: counter width must be 2, 4, 8, 16 or 32")
+	}
+	return opt
+}
+
+func (cbf *CountingBloomfilter) Add(val string) {
+	cbf.AddBytes([]byte(val))
+}
+
+func (cbf *CountingBloomfilter) AddBytes(val []byte) {
+	cbf.l.Lock()
+	defer cbf.l.Unlock()
+	locations(cbf.h, cbf.m, cbf.k, val, func(pos uint64) bool {
+		if c := cbf.counter(pos); c < cbf.max() {
+			cbf.setCounter(pos, c+1)
+		}
+		return true
+	})
+}
+
+// Remove decrements the counters of val. It returns false and changes nothing
+// if val is definitely not in the filter.
+func (cbf *CountingBloomfilter) Remove(val string) bool {
+	return cbf.RemoveBytes([]byte(val))
+}
+
+func (cbf *CountingBloomfilter) RemoveBytes(val []byte) bool {
+	cbf.l.Lock()
+	defer cbf.l.Unlock()
+	if !locations(cbf.h, cbf.m, cbf.k, val, cbf.isSet) {
+		return false
+	}
+	locations(cbf.h, cbf.m, cbf.k, val, func(pos uint64) bool {
+		if c := cbf.counter(pos); c > 0 && c < cbf.max() {
+			cbf.setCounter(pos, c-1)
+		}
+		return true
+	})
+	return true
+}
+
+func (cbf *CountingBloomfilter) Test(val string) bool {
+	return cbf.TestBytes([]byte(val))
+}
+
+func (cbf *CountingBloomfilter) TestBytes(val []byte) bool {
+	cbf.l.RLock()
+	defer cbf.l.RUnlock()
+	return locations(cbf.h, cbf.m, cbf.k, val, cbf.isSet)
+}
+
+// Count estimates how many times val was added, as the smallest of its counters.
+// It never underestimates unless counters saturated or other values were wrongly removed.
+func (cbf *CountingBloomfilter) Count(val string) uint64 {
+	cbf.l.RLock()
+	defer cbf.l.RUnlock()
+	count := cbf.max()
+	locations(cbf.h, cbf.m, cbf.k, []byte(val), func(pos uint64) bool {
+		if c := cbf.counter(pos); c < count {
+			count = c
+		}
+		return count > 0
+	})
+	return count
+}
+
+// Data returns m, k, the counter width and the hash algorithm as uint64s, followed by the counters.
+func (cbf *CountingBloomfilter) Data() []byte {
+	cbf.l.RLock()
+	defer cbf.l.RUnlock()
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.LittleEndian, cbf.m)
+	binary.Write(buf, binary.LittleEndian, cbf.k)
+	binary.Write(buf, binary.LittleEndian, cbf.width)
+	binary.Write(buf, binary.LittleEndian, uint64(cbf.h))
+	binary.Write(buf, binary.LittleEndian, cbf.counters)
+	return buf.Bytes()
+}
+
+func (cbf *CountingBloomfilter) perWord() uint64 {
+	return 64 / cbf.width
+}
+
+func (cbf *CountingBloomfilter) max() uint64 {
+	return 1<<cbf.width - 1
+}
+
+func (cbf *CountingBloomfilter) isSet(pos uint64) bool {
+	return cbf.counter(pos) > 0
+}
+
+func (cbf *CountingBloomfilter) counter(pos uint64) uint64 {
+	shift := pos % cbf.perWord() * cbf.width
+	return cbf.counters[pos/cbf.perWord()] >> shift & cbf.max()
+}
+
+func (cbf *CountingBloomfilter) setCounter(pos uint64, c uint64) {
+	shift := pos % cbf.perWord() * cbf.width
+	word := &cbf.counters[pos/cbf.perWord()]
+	*word = *word&^(cbf.max()<<shift) | c<<shift
+}
@@ -0,0 +1,221 @@
+package bloomfilter
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"goalds/ds/bitmap"
+	"os"
+)
+
+// The layout of a mapped filter file, little endian:
+//
+//	magic "GBLM" | version uint16 | hash algorithm uint8 | reserved to 16 bytes | m uint64 | k uint64 |
+//	reserved to 64 bytes | bits
+//
+// The bits start at offset 64 so that, mapped at a page boundary, they are cache-line aligned.
+const (
+	mappedMagic      = "GBLM"
+	mappedVersion    = uint16(1)
+	mappedHeaderSize = 64
+)
+
+var ErrInvalidMappedFile = errors.New("bloomfilter: not a mapped filter file")
+
+// MappedBloomfilter is a Bloomfilter whose bits live in a file mapped into memory, so they
+// stay off the Go heap and survive restarts. Where memory mapping is not available the file
+// is read into memory instead and written back by Sync and Close.
+type MappedBloomfilter struct {
+	bf       *Bloomfilter
+	file     *os.File
+	mapping  []byte
+	readOnly bool
+}
+
+// CreateMapped creates or truncates the file at path and maps a new, empty filter of m bits
+// and k hash functions from it. WithHashAlgorithm is recorded in the file header.
+func CreateMapped(path string, m uint64, k uint64, opts ...Option) (*MappedBloomfilter, error) {
+	if m == 0 || k == 0 {
+		return nil, fmt.Errorf("bloomfilter: invalid parameters m=%d k=%d", m, k)
+	}
+	opt := &Options{
+		locker: defaultLocker,
+		hash:   HashMurmur3,
+	}
+	for _, o := range opts {
+		o(opt)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, mappedHeaderSize)
+	copy(header, mappedMagic)
+	binary.LittleEndian.PutUint16(header[4:], mappedVersion)
+	header[6] = byte(opt.hash)
+	binary.LittleEndian.PutUint64(header[16:], m)
+	binary.LittleEndian.PutUint64(header[24:], k)
+	if _, err := file.Write(header); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if err := file.Truncate(int64(mappedHeaderSize + (m+7)/8)); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return newMapped(file, false, opts)
+}
+
+// OpenMapped maps the filter stored in the file at path for reading and writing.
+// The parameters and hash algorithm come from the file header.
+func OpenMapped(path string, opts ...Option) (*MappedBloomfilter, error) {
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	return newMapped(file, false, opts)
+}
+
+// OpenMappedReadOnly maps the filter stored in the file at path for queries only.
+// Add and TestAndAdd panic.
+func OpenMappedReadOnly(path string, opts ...Option) (*MappedBloomfilter, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return newMapped(file, true, opts)
+}
+
+func newMapped(file *os.File, readOnly bool, opts []Option) (*MappedBloomfilter, error) {
+	opt := &Options{locker: defaultLocker}
+	for _, o := range opts {
+		o(opt)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	size := info.Size()
+	if size < mappedHeaderSize || int64(int(size)) != size {
+		file.Close()
+		return nil, fmt.Errorf("bloomfilter: invalid mapped file size %d", size)
+	}
+	mapping, err := mapFile(file, int(size), !readOnly)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	mbf := &MappedBloomfilter{
+		file:     file,
+		mapping:  mapping,
+		readOnly: readOnly,
+	}
+	if err := mbf.parseHeader(opt); err != nil {
+		unmapFile(file, mapping, false)
+		file.Close()
+		return nil, err
+	}
+	return mbf, nil
+}
+
+func (mbf *MappedBloomfilter) parseHeader(opt *Options) error {
+	header := mbf.mapping[:mappedHeaderSize]
+	if string(header[:4]) != mappedMagic {
+		return ErrInvalidMappedFile
+	}
+	if version := binary.LittleEndian.Uint16(header[4:]); version != mappedVersion {
+		return fmt.Errorf("bloomfilter: unsupported format version %d", version)
+	}
+	h := HashAlgorithm(header[6])
+	if h != HashMurmur3 && h != HashSHA512 {
+		return fmt.Errorf("bloomfilter: unknown hash algorithm %d", h)
+	}
+	m := binary.LittleEndian.Uint64(header[16:])
+	k := binary.LittleEndian.Uint64(header[24:])
+	if m == 0 || k == 0 || (m+7)/8 > uint64(len(mbf.mapping)-mappedHeaderSize) {
+		return fmt.Errorf("bloomfilter: invalid parameters m=%d k=%d", m, k)
+	}
+	bits := mbf.mapping[mappedHeaderSize : mappedHeaderSize+(m+7)/8]
+	mbf.bf = &Bloomfilter{
+		m: m,
+		k: k,
+		b: bitmap.Wrap(bits),
+		l: opt.locker,
+		h: h,
+	}
+	return nil
+}
+
+func (mbf *MappedBloomfilter) Add(val string) {
+	mbf.AddBytes([]byte(val))
+}
+
+func (mbf *MappedBloomfilter) AddBytes(val []byte) {
+	mbf.checkWritable()
+	mbf.bf.AddBytes(val)
+}
+
+func (mbf *MappedBloomfilter) Test(val string) bool {
+	return mbf.bf.Test(val)
+}
+
+func (mbf *MappedBloomfilter) TestBytes(val []byte) bool {
+	return mbf.bf.TestBytes(val)
+}
+
+func (mbf *MappedBloomfilter) TestAndAdd(val string) bool {
+	return mbf.TestAndAddBytes([]byte(val))
+}
+
+func (mbf *MappedBloomfilter) TestAndAddBytes(val []byte) bool {
+	mbf.checkWritable()
+	return mbf.bf.TestAndAddBytes(val)
+}
+
+func (mbf *MappedBloomfilter) FillRatio() float64 {
+	return mbf.bf.FillRatio()
+}
+
+func (mbf *MappedBloomfilter) EstimatedCount() uint64 {
+	return mbf.bf.EstimatedCount()
+}
+
+func (mbf *MappedBloomfilter) EstimatedFalsePositiveRate() float64 {
+	return mbf.bf.EstimatedFalsePositiveRate()
+}
+
+// Data returns the filter in the legacy layout of Bloomfilter.Data().
+func (mbf *MappedBloomfilter) Data() []byte {
+	return mbf.bf.Data()
+}
+
+// Sync flushes the bits to the file and waits until they are written.
+// It does nothing on a read-only filter.
+func (mbf *MappedBloomfilter) Sync() error {
+	if mbf.readOnly {
+		return nil
+	}
+	mbf.bf.l.RLock()
+	defer mbf.bf.l.RUnlock()
+	return syncFile(mbf.file, mbf.mapping)
+}
+
+// Close unmaps and closes the file. Bits not yet synced still reach the file, but
+// without the guarantee of Sync. The filter must not be used afterwards.
+func (mbf *MappedBloomfilter) Close() error {
+	mbf.bf.l.Lock()
+	defer mbf.bf.l.Unlock()
+	err := unmapFile(mbf.file, mbf.mapping, !mbf.readOnly)
+	if closeErr := mbf.file.Close(); err == nil {
+		err = closeErr
+	}
+	mbf.mapping = nil
+	return err
+}
+
+func (mbf *MappedBloomfilter) checkWritable() {
+	if mbf.readOnly {
+		panic("bloomfilter: filter is read-only")
+	}
+}
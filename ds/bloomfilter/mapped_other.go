@@ -0,0 +1,32 @@
+//go:build !(linux || darwin || freebsd || dragonfly)
+
+package bloomfilter
+
+import (
+	"io"
+	"os"
+)
+
+// mapFile reads the whole file, since memory mapping is not supported here.
+func mapFile(file *os.File, size int, _ bool) ([]byte, error) {
+	data := make([]byte, size)
+	if _, err := file.ReadAt(data, 0); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return data, nil
+}
+
+func syncFile(file *os.File, mapping []byte) error {
+	if _, err := file.WriteAt(mapping, 0); err != nil {
+		return err
+	}
+	return file.Sync()
+}
+
+func unmapFile(file *os.File, mapping []byte, writable bool) error {
+	if !writable {
+		return nil
+	}
+	_, err := file.WriteAt(mapping, 0)
+	return err
+}
@@ -0,0 +1,30 @@
+//go:build linux || darwin || freebsd || dragonfly
+
+package bloomfilter
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+func mapFile(file *os.File, size int, writable bool) ([]byte, error) {
+	prot := syscall.PROT_READ
+	if writable {
+		prot |= syscall.PROT_WRITE
+	}
+	return syscall.Mmap(int(file.Fd()), 0, size, prot, syscall.MAP_SHARED)
+}
+
+func syncFile(_ *os.File, mapping []byte) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_MSYNC, uintptr(unsafe.Pointer(&mapping[0])), uintptr(len(mapping)), syscall.MS_SYNC)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// unmapFile leaves writing back to the kernel, which does it for shared mappings.
+func unmapFile(_ *os.File, mapping []byte, _ bool) error {
+	return syscall.Munmap(mapping)
+}
@@ -0,0 +1,232 @@
+package bloomfilter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"goalds/utils/locker"
+	"math"
+)
+
+const (
+	defaultGrowth     = 2
+	defaultTightening = 0.85
+)
+
+// WithGrowth sets how much larger each new stage of a ScalableBloomfilter is than the previous one, 2 by default.
+func WithGrowth(growth uint64) Option {
+	return func(opt *Options) {
+		opt.growth = growth
+	}
+}
+
+// WithTightening sets the ratio in (0, 1) applied to the false positive rate of each new stage
+// of a ScalableBloomfilter, 0.85 by default. The overall rate stays below fp whatever the
+// ratio; smaller ratios give the first stages a larger share of it and later stages less.
+func WithTightening(ratio float64) Option {
+	return func(opt *Options) {
+		opt.tightening = ratio
+	}
+}
+
+// ScalableBloomfilter is a Bloom filter that grows with insertions (Almeida et al., 2007).
+// It chains stages of increasing capacity n*s^i and tightening false positive rate
+// fp*(1-r)*r^i, starting a new stage whenever the last one is full. The rates sum to fp,
+// so the overall false positive rate stays below fp however many elements are added.
+type ScalableBloomfilter struct {
+	n          uint64
+	fp         float64
+	growth     uint64
+	tightening float64
+	stages     []*Bloomfilter
+	counts     []uint64
+	l          locker.Locker
+	h          HashAlgorithm
+}
+
+// NewScalable creates a scalable Bloom filter whose first stage holds n elements, and whose
+// overall false positive rate is bounded by fp.
+func NewScalable(n uint64, fp float64, opts ...Option) *ScalableBloomfilter {
+	opt := scalableOptions(opts)
+	sbf := &ScalableBloomfilter{
+		n:          n,
+		fp:         fp,
+		growth:     opt.growth,
+		tightening: opt.tightening,
+		l:          opt.locker,
+		h:          opt.hash,
+	}
+	sbf.addStage()
+	return sbf
+}
+
+// NewScalableFromData restores a filter from ScalableBloomfilter.Data(). It returns nil if data
+// is truncated or invalid.
+func NewScalableFromData(data []byte, opts ...Option) *ScalableBloomfilter {
+	opt := scalableOptions(opts)
+	sbf := &ScalableBloomfilter{
+		l: opt.locker,
+		h: opt.hash,
+	}
+	reader := bytes.NewReader(data)
+	var stages uint64
+	for _, field := range []any{&sbf.n, &sbf.fp, &sbf.growth, &sbf.tightening, &stages} {
+		if binary.Read(reader, binary.LittleEndian, field) != nil {
+			return nil
+		}
+	}
+	if sbf.n == 0 || !(sbf.fp > 0 && sbf.fp < 1) || sbf.growth < 1 ||
+		!(sbf.tightening > 0 && sbf.tightening < 1) || stages == 0 {
+		return nil
+	}
+	for i := uint64(0); i < stages; i++ {
+		var count, size uint64
+		if binary.Read(reader, binary.LittleEndian, &count) != nil ||
+			binary.Read(reader, binary.LittleEndian, &size) != nil || size > uint64(reader.Len()) {
+			return nil
+		}
+		stage := make([]byte, size)
+		reader.Read(stage)
+		bf := NewFromData(stage)
+		if bf == nil {
+			return nil
+		}
+		// new stages use the algorithm the data recorded
+		sbf.h = bf.h
+		sbf.stages = append(sbf.stages, bf)
+		sbf.counts = append(sbf.counts, count)
+	}
+	if reader.Len() > 0 {
+		return nil
+	}
+	return sbf
+}
+
+func scalableOptions(opts []Option) *Options {
+	opt := &Options{
+		locker:     defaultLocker,
+		hash:       HashMurmur3,
+		growth:     defaultGrowth,
+		tightening: defaultTightening,
+	}
+	for _, o := range opts {
+		o(opt)
+	}
+	if opt.growth < 1 {
+		panic("bloomfilter: growth must be at least 1")
+	}
+	if opt.tightening <= 0 || opt.tightening >= 1 {
+		panic("bloomfilter: tightening ratio must be in (0, 1)")
+	}
+	return opt
+}
+
+func (sbf *ScalableBloomfilter) Add(val string) {
+	sbf.AddBytes([]byte(val))
+}
+
+func (sbf *ScalableBloomfilter) AddBytes(val []byte) {
+	sbf.TestAndAddBytes(val)
+}
+
+func (sbf *ScalableBloomfilter) Test(val string) bool {
+	return sbf.TestBytes([]byte(val))
+}
+
+func (sbf *ScalableBloomfilter) TestBytes(val []byte) bool {
+	sbf.l.RLock()
+	defer sbf.l.RUnlock()
+	return sbf.test(val)
+}
+
+// TestAndAdd adds val and reports whether it was already present. Values that test
+// positive are not added again, so they do not use up the capacity of the last stage.
+func (sbf *ScalableBloomfilter) TestAndAdd(val string) bool {
+	return sbf.TestAndAddBytes([]byte(val))
+}
+
+func (sbf *ScalableBloomfilter) TestAndAddBytes(val []byte) bool {
+	sbf.l.Lock()
+	defer sbf.l.Unlock()
+	if sbf.test(val) {
+		return true
+	}
+	last := len(sbf.stages) - 1
+	if sbf.counts[last] >= sbf.capacity(last) {
+		sbf.addStage()
+		last++
+	}
+	sbf.stages[last].AddBytes(val)
+	sbf.counts[last]++
+	return false
+}
+
+// Count returns the number of values added, not counting values that already tested positive.
+func (sbf *ScalableBloomfilter) Count() uint64 {
+	sbf.l.RLock()
+	defer sbf.l.RUnlock()
+	total := uint64(0)
+	for _, c := range sbf.counts {
+		total += c
+	}
+	return total
+}
+
+// Stages returns the number of sub-filters.
+func (sbf *ScalableBloomfilter) Stages() int {
+	sbf.l.RLock()
+	defer sbf.l.RUnlock()
+	return len(sbf.stages)
+}
+
+// FalsePositiveRate estimates the current false positive rate from the number of
+// values in every stage: 1 - prod(1 - (1 - e^(-k*n_i/m_i))^k).
+func (sbf *ScalableBloomfilter) FalsePositiveRate() float64 {
+	sbf.l.RLock()
+	defer sbf.l.RUnlock()
+	negative := 1.0
+	for i, stage := range sbf.stages {
+		k, m := float64(stage.k), float64(stage.m)
+		negative *= 1 - math.Pow(1-math.Exp(-k*float64(sbf.counts[i])/m), k)
+	}
+	return 1 - negative
+}
+
+func (sbf *ScalableBloomfilter) Data() []byte {
+	sbf.l.RLock()
+	defer sbf.l.RUnlock()
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.LittleEndian, sbf.n)
+	binary.Write(buf, binary.LittleEndian, sbf.fp)
+	binary.Write(buf, binary.LittleEndian, sbf.growth)
+	binary.Write(buf, binary.LittleEndian, sbf.tightening)
+	binary.Write(buf, binary.LittleEndian, uint64(len(sbf.stages)))
+	for i, stage := range sbf.stages {
+		data := stage.Data()
+		binary.Write(buf, binary.LittleEndian, sbf.counts[i])
+		binary.Write(buf, binary.LittleEndian, uint64(len(data)))
+		buf.Write(data)
+	}
+	return buf.Bytes()
+}
+
+func (sbf *ScalableBloomfilter) test(val []byte) bool {
+	for _, stage := range sbf.stages {
+		if stage.TestBytes(val) {
+			return true
+		}
+	}
+	return false
+}
+
+// capacity returns the number of values stage i was sized for, n*s^i.
+func (sbf *ScalableBloomfilter) capacity(i int) uint64 {
+	return sbf.n * uint64(math.Pow(float64(sbf.growth), float64(i)))
+}
+
+// addStage appends stage i, sized for the false positive rate fp*(1-r)*r^i.
+func (sbf *ScalableBloomfilter) addStage() {
+	i := len(sbf.stages)
+	fp := sbf.fp * (1 - sbf.tightening) * math.Pow(sbf.tightening, float64(i))
+	sbf.stages = append(sbf.stages, NewWithEstimates(sbf.capacity(i), fp, WithHashAlgorithm(sbf.h)))
+	sbf.counts = append(sbf.counts, 0)
+}
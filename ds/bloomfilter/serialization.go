@@ -0,0 +1,184 @@
+package bloomfilter
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"goalds/ds/bitmap"
+	"goalds/utils/serialize"
+	"hash/crc32"
+	"io"
+)
+
+// The versioned layout written by WriteTo and MarshalBinary, little endian:
+//
+//	magic "GBLF" | version uint16 | hash algorithm uint8 | m uint64 | k uint64 | bits | CRC32 of all previous bytes
+//
+// The legacy layout, which Data() wrote before the versioned one existed, is
+// m uint64 | k uint64 | bits. Only the original HashSHA512 scheme wrote it, so readers load
+// it as HashSHA512, and Data() keeps writing it for such filters only. Readers tell them
+// apart by the magic, which only collides with a legacy m whose low 32 bits are 0x464c4247.
+const (
+	serializationMagic   = "GBLF"
+	serializationVersion = uint16(1)
+)
+
+var (
+	ErrChecksumMismatch = errors.New("bloomfilter: checksum mismatch")
+	ErrTrailingData     = errors.New("bloomfilter: trailing data")
+)
+
+var (
+	_ io.WriterTo                = &Bloomfilter{}
+	_ io.ReaderFrom              = &Bloomfilter{}
+	_ encoding.BinaryMarshaler   = &Bloomfilter{}
+	_ encoding.BinaryUnmarshaler = &Bloomfilter{}
+)
+
+// WriteTo writes the filter to w in the versioned layout.
+func (bf *Bloomfilter) WriteTo(w io.Writer) (int64, error) {
+	bf.l.RLock()
+	defer bf.l.RUnlock()
+	return writeVersioned(w, bf.h, bf.m, bf.k, bf.b.Data())
+}
+
+func writeVersioned(w io.Writer, h HashAlgorithm, m, k uint64, bits []byte) (int64, error) {
+	cw := &serialize.CountingWriter{W: w}
+	crc := crc32.NewIEEE()
+	mw := io.MultiWriter(cw, crc)
+	header := make([]byte, 0, 23)
+	header = append(header, serializationMagic...)
+	header = binary.LittleEndian.AppendUint16(header, serializationVersion)
+	header = append(header, byte(h))
+	header = binary.LittleEndian.AppendUint64(header, m)
+	header = binary.LittleEndian.AppendUint64(header, k)
+	if _, err := mw.Write(header); err != nil {
+		return cw.N, err
+	}
+	if _, err := mw.Write(bits); err != nil {
+		return cw.N, err
+	}
+	err := binary.Write(cw, binary.LittleEndian, crc.Sum32())
+	return cw.N, err
+}
+
+// encode returns the layout Data() writes: the legacy layout for HashSHA512, byte for byte
+// what Data() always wrote for it, and the versioned layout otherwise.
+func encode(h HashAlgorithm, m, k uint64, bits []byte) []byte {
+	buf := new(bytes.Buffer)
+	if h != HashSHA512 {
+		writeVersioned(buf, h, m, k, bits)
+		return buf.Bytes()
+	}
+	binary.Write(buf, binary.LittleEndian, m)
+	binary.Write(buf, binary.LittleEndian, k)
+	buf.Write(bits)
+	return buf.Bytes()
+}
+
+// ReadFrom replaces the filter with one read from r, in either the versioned or the legacy layout.
+// The legacy layout is read as HashSHA512. On error bf is left untouched.
+func (bf *Bloomfilter) ReadFrom(r io.Reader) (int64, error) {
+	cr := &serialize.CountingReader{R: r}
+	crc := crc32.NewIEEE()
+	tr := io.TeeReader(cr, crc)
+
+	magic := make([]byte, len(serializationMagic))
+	if _, err := io.ReadFull(tr, magic); err != nil {
+		return cr.N, serialize.Truncated("bloomfilter", err)
+	}
+	if string(magic) != serializationMagic {
+		return cr.N, bf.readLegacy(io.MultiReader(bytes.NewReader(magic), cr))
+	}
+
+	var version uint16
+	if err := binary.Read(tr, binary.LittleEndian, &version); err != nil {
+		return cr.N, serialize.Truncated("bloomfilter", err)
+	}
+	if version != serializationVersion {
+		return cr.N, fmt.Errorf("bloomfilter: unsupported format version %d", version)
+	}
+	var h uint8
+	if err := binary.Read(tr, binary.LittleEndian, &h); err != nil {
+		return cr.N, serialize.Truncated("bloomfilter", err)
+	}
+	if HashAlgorithm(h) != HashMurmur3 && HashAlgorithm(h) != HashSHA512 {
+		return cr.N, fmt.Errorf("bloomfilter: unknown hash algorithm %d", h)
+	}
+	m, k, data, err := readBody(tr)
+	if err != nil {
+		return cr.N, err
+	}
+	sum := crc.Sum32()
+	var expected uint32
+	if err := binary.Read(cr, binary.LittleEndian, &expected); err != nil {
+		return cr.N, serialize.Truncated("bloomfilter", err)
+	}
+	if sum != expected {
+		return cr.N, ErrChecksumMismatch
+	}
+	bf.replace(m, k, HashAlgorithm(h), data)
+	return cr.N, nil
+}
+
+func (bf *Bloomfilter) MarshalBinary() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	_, err := bf.WriteTo(buf)
+	return buf.Bytes(), err
+}
+
+// UnmarshalBinary replaces the filter with data in either the versioned or the legacy layout.
+func (bf *Bloomfilter) UnmarshalBinary(data []byte) error {
+	reader := bytes.NewReader(data)
+	if _, err := bf.ReadFrom(reader); err != nil {
+		return err
+	}
+	if reader.Len() > 0 {
+		return ErrTrailingData
+	}
+	return nil
+}
+
+func (bf *Bloomfilter) readLegacy(r io.Reader) error {
+	m, k, data, err := readBody(r)
+	if err != nil {
+		return err
+	}
+	bf.replace(m, k, HashSHA512, data)
+	return nil
+}
+
+// readBody reads m, k and the (m+7)/8 bytes of bits.
+func readBody(r io.Reader) (uint64, uint64, []byte, error) {
+	var m, k uint64
+	if err := binary.Read(r, binary.LittleEndian, &m); err != nil {
+		return 0, 0, nil, serialize.Truncated("bloomfilter", err)
+	}
+	if err := binary.Read(r, binary.LittleEndian, &k); err != nil {
+		return 0, 0, nil, serialize.Truncated("bloomfilter", err)
+	}
+	if m == 0 || k == 0 {
+		return 0, 0, nil, fmt.Errorf("bloomfilter: invalid parameters m=%d k=%d", m, k)
+	}
+	size := int64((m + 7) / 8)
+	if size <= 0 {
+		return 0, 0, nil, fmt.Errorf("bloomfilter: invalid parameters m=%d k=%d", m, k)
+	}
+	bits, err := serialize.ReadN(r, size)
+	if err != nil {
+		return 0, 0, nil, serialize.Truncated("bloomfilter", err)
+	}
+	return m, k, bits, nil
+}
+
+func (bf *Bloomfilter) replace(m, k uint64, h HashAlgorithm, data []byte) {
+	if bf.l == nil {
+		bf.l = defaultLocker
+	}
+	bf.l.Lock()
+	defer bf.l.Unlock()
+	bf.m, bf.k, bf.h = m, k, h
+	bf.b = bitmap.NewFromBits(data)
+}
@@ -0,0 +1,91 @@
+package bloomfilter
+
+import (
+	"encoding/binary"
+	"goalds/al/hash"
+	"goalds/utils/comparator"
+)
+
+// Hasher returns two independent 64-bit hashes of a value, from which
+// a TypedBloomfilter derives its k bit positions by double hashing.
+type Hasher[T any] func(val T) (uint64, uint64)
+
+func StringHasher(val string) (uint64, uint64) {
+	return hash.Murmur3Sum128([]byte(val), 0)
+}
+
+func BytesHasher(val []byte) (uint64, uint64) {
+	return hash.Murmur3Sum128(val, 0)
+}
+
+func IntegerHasher[T comparator.Integer](val T) (uint64, uint64) {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], uint64(val))
+	return hash.Murmur3Sum128(buf[:], 0)
+}
+
+// TypedBloomfilter is a Bloomfilter for values of any type, hashed by a Hasher
+// instead of being converted to strings. WithHashAlgorithm has no effect on it.
+type TypedBloomfilter[T any] struct {
+	bf     *Bloomfilter
+	hasher Hasher[T]
+}
+
+func NewTyped[T any](m uint64, k uint64, hasher Hasher[T], opts ...Option) *TypedBloomfilter[T] {
+	return &TypedBloomfilter[T]{
+		bf:     New(m, k, opts...),
+		hasher: hasher,
+	}
+}
+
+func NewTypedWithEstimates[T any](n uint64, fp float64, hasher Hasher[T], opts ...Option) *TypedBloomfilter[T] {
+	m, k := EstimateParameters(n, fp)
+	return NewTyped(m, k, hasher, opts...)
+}
+
+// NewTypedFromData restores a filter from TypedBloomfilter.Data(). It returns nil if data is
+// truncated or corrupted.
+func NewTypedFromData[T any](data []byte, hasher Hasher[T], opts ...Option) *TypedBloomfilter[T] {
+	bf := NewFromData(data, opts...)
+	if bf == nil {
+		return nil
+	}
+	return &TypedBloomfilter[T]{
+		bf:     bf,
+		hasher: hasher,
+	}
+}
+
+func (tf *TypedBloomfilter[T]) Add(val T) {
+	h1, h2 := tf.hasher(val)
+	tf.bf.l.Lock()
+	defer tf.bf.l.Unlock()
+	doubleHash(tf.bf.m, tf.bf.k, h1, h2, tf.bf.b.Set)
+}
+
+func (tf *TypedBloomfilter[T]) Test(val T) bool {
+	h1, h2 := tf.hasher(val)
+	tf.bf.l.RLock()
+	defer tf.bf.l.RUnlock()
+	return doubleHash(tf.bf.m, tf.bf.k, h1, h2, tf.bf.b.IsSet)
+}
+
+// TestAndAdd adds val and reports whether it was already present, in one locked pass.
+func (tf *TypedBloomfilter[T]) TestAndAdd(val T) bool {
+	h1, h2 := tf.hasher(val)
+	tf.bf.l.Lock()
+	defer tf.bf.l.Unlock()
+	present := true
+	doubleHash(tf.bf.m, tf.bf.k, h1, h2, func(pos uint64) bool {
+		if !tf.bf.b.IsSet(pos) {
+			present = false
+			tf.bf.b.Set(pos)
+		}
+		return true
+	})
+	return present
+}
+
+func (tf *TypedBloomfilter[T]) Data() []byte {
+	return tf.bf.Data()
+}
@@ -0,0 +1,310 @@
+package cuckoofilter
+
+import (
+	"errors"
+	"goalds/al/hash"
+	"goalds/utils/locker"
+	"math"
+	"math/bits"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const (
+	defaultFingerprintBits = 16
+	defaultBucketSize      = 4
+	defaultMaxKicks        = 500
+	// altIndexMul spreads a fingerprint over the buckets to find its alternate bucket.
+	altIndexMul = 0x5bd1e995
+)
+
+var defaultLocker locker.FakeLocker
+
+var ErrFull = errors.New("cuckoofilter: filter is full")
+
+type Options struct {
+	locker          locker.Locker
+	fingerprintBits uint64
+	bucketSize      uint64
+	maxKicks        int
+}
+
+type Option func(opt *Options)
+
+func WithGoroutineSafe() Option {
+	return func(opt *Options) {
+		opt.locker = &sync.RWMutex{}
+	}
+}
+
+// WithFingerprintBits sets the bits stored per value, 1 to 32, 16 by default.
+// More bits lower the false positive rate, which is about 2*bucketSize/2^bits.
+func WithFingerprintBits(bits uint64) Option {
+	return func(opt *Options) {
+		opt.fingerprintBits = bits
+	}
+}
+
+// WithBucketSize sets the number of fingerprints per bucket, 4 by default.
+// Larger buckets reach a higher load factor but raise the false positive rate.
+func WithBucketSize(size uint64) Option {
+	return func(opt *Options) {
+		opt.bucketSize = size
+	}
+}
+
+// WithMaxKicks bounds how many fingerprints an Insert may relocate before giving up with ErrFull.
+func WithMaxKicks(kicks int) Option {
+	return func(opt *Options) {
+		opt.maxKicks = kicks
+	}
+}
+
+// CuckooFilter is an approximate membership structure that, unlike a Bloom filter, supports
+// deletion (Fan et al., 2014). It stores a short fingerprint of every value in one of two
+// candidate buckets and relocates existing fingerprints to make room, like cuckoo hashing.
+// With 4-slot buckets it can typically be filled to about 95% of its slots.
+type CuckooFilter struct {
+	buckets         uint64
+	bucketSize      uint64
+	fingerprintBits uint64
+	count           uint64
+	slots           []uint64
+	maxKicks        int
+	l               locker.Locker
+	rander          *rand.Rand
+}
+
+// New creates a filter with room for at least capacity fingerprints.
+func New(capacity uint64, opts ...Option) *CuckooFilter {
+	opt := newOptions(opts)
+	buckets := (capacity + opt.bucketSize - 1) / opt.bucketSize
+	if buckets < 1 {
+		buckets = 1
+	}
+	buckets = 1 << bits.Len64(buckets-1)
+	return newFilter(buckets, opt)
+}
+
+// NewWithEstimates creates a filter for n values with the false positive rate fp,
+// choosing the fingerprint size and leaving room for a 95% load factor.
+func NewWithEstimates(n uint64, fp float64, opts ...Option) *CuckooFilter {
+	opt := newOptions(opts)
+	fingerprintBits, capacity := EstimateParameters(n, fp, opt.bucketSize)
+	// copy opts so that appending never writes into the caller's array
+	opts = append(append([]Option(nil), opts...), WithFingerprintBits(fingerprintBits))
+	return New(capacity, opts...)
+}
+
+// EstimateParameters returns the fingerprint bits and the capacity to hold n values
+// with the false positive rate fp when buckets have bucketSize slots.
+func EstimateParameters(n uint64, fp float64, bucketSize uint64) (uint64, uint64) {
+	fingerprintBits := uint64(math.Ceil(math.Log2(2 * float64(bucketSize) / fp)))
+	if fingerprintBits < 1 {
+		fingerprintBits = 1
+	}
+	if fingerprintBits > 32 {
+		fingerprintBits = 32
+	}
+	capacity := uint64(math.Ceil(float64(n) / 0.95))
+	return fingerprintBits, capacity
+}
+
+// NewFromData restores a filter from Data(). It returns nil if data is truncated or corrupted.
+func NewFromData(data []byte, opts ...Option) *CuckooFilter {
+	cf := newFilter(1, newOptions(opts))
+	if err := cf.UnmarshalBinary(data); err != nil {
+		return nil
+	}
+	return cf
+}
+
+func newOptions(opts []Option) *Options {
+	opt := &Options{
+		locker:          defaultLocker,
+		fingerprintBits: defaultFingerprintBits,
+		bucketSize:      defaultBucketSize,
+		maxKicks:        defaultMaxKicks,
+	}
+	for _, o := range opts {
+		o(opt)
+	}
+	if opt.fingerprintBits < 1 || opt.fingerprintBits > 32 {
+		panic("cuckoofilter: fingerprint bits must be in [1, 32]")
+	}
+	if opt.bucketSize < 1 {
+		panic("cuckoofilter: bucket size must be at least 1")
+	}
+	if opt.maxKicks < 0 {
+		panic("cuckoofilter: max kicks must not be negative")
+	}
+	return opt
+}
+
+func slotWords(buckets uint64, opt *Options) uint64 {
+	return (buckets*opt.bucketSize*opt.fingerprintBits + 63) / 64
+}
+
+func newFilter(buckets uint64, opt *Options) *CuckooFilter {
+	return &CuckooFilter{
+		buckets:         buckets,
+		bucketSize:      opt.bucketSize,
+		fingerprintBits: opt.fingerprintBits,
+		slots:           make([]uint64, slotWords(buckets, opt)),
+		maxKicks:        opt.maxKicks,
+		l:               opt.locker,
+		rander:          rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Insert adds val. It returns ErrFull, leaving the filter unchanged, if no room
+// could be made within the relocation bound.
+func (cf *CuckooFilter) Insert(val string) error {
+	return cf.InsertBytes([]byte(val))
+}
+
+func (cf *CuckooFilter) InsertBytes(val []byte) error {
+	fp, i1, i2 := cf.locate(val)
+	cf.l.Lock()
+	defer cf.l.Unlock()
+	if cf.insertInto(i1, fp) || cf.insertInto(i2, fp) {
+		cf.count++
+		return nil
+	}
+
+	type kick struct {
+		slot uint64
+		fp   uint64
+	}
+	kicks := make([]kick, 0, cf.maxKicks)
+	i := i1
+	if cf.rander.Intn(2) == 0 {
+		i = i2
+	}
+	for n := 0; n < cf.maxKicks; n++ {
+		slot := i*cf.bucketSize + uint64(cf.rander.Int63n(int64(cf.bucketSize)))
+		victim := cf.slot(slot)
+		cf.setSlot(slot, fp)
+		kicks = append(kicks, kick{slot: slot, fp: victim})
+		fp = victim
+		i = cf.altIndex(i, fp)
+		if cf.insertInto(i, fp) {
+			cf.count++
+			return nil
+		}
+	}
+	// undo the relocations so that no fingerprint is lost
+	for n := len(kicks) - 1; n >= 0; n-- {
+		cf.setSlot(kicks[n].slot, kicks[n].fp)
+	}
+	return ErrFull
+}
+
+// Lookup reports whether val may be in the filter. False positives are possible, false negatives are not.
+func (cf *CuckooFilter) Lookup(val string) bool {
+	return cf.LookupBytes([]byte(val))
+}
+
+func (cf *CuckooFilter) LookupBytes(val []byte) bool {
+	fp, i1, i2 := cf.locate(val)
+	cf.l.RLock()
+	defer cf.l.RUnlock()
+	return cf.find(i1, fp) < cf.bucketSize || cf.find(i2, fp) < cf.bucketSize
+}
+
+// Delete removes one copy of val. Only delete values that were inserted:
+// deleting a false positive removes the fingerprint of another value.
+func (cf *CuckooFilter) Delete(val string) bool {
+	return cf.DeleteBytes([]byte(val))
+}
+
+func (cf *CuckooFilter) DeleteBytes(val []byte) bool {
+	fp, i1, i2 := cf.locate(val)
+	cf.l.Lock()
+	defer cf.l.Unlock()
+	for _, i := range []uint64{i1, i2} {
+		if j := cf.find(i, fp); j < cf.bucketSize {
+			cf.setSlot(i*cf.bucketSize+j, 0)
+			cf.count--
+			return true
+		}
+	}
+	return false
+}
+
+// Count returns the number of fingerprints in the filter.
+func (cf *CuckooFilter) Count() uint64 {
+	cf.l.RLock()
+	defer cf.l.RUnlock()
+	return cf.count
+}
+
+// LoadFactor returns the fraction of slots in use.
+func (cf *CuckooFilter) LoadFactor() float64 {
+	cf.l.RLock()
+	defer cf.l.RUnlock()
+	return float64(cf.count) / float64(cf.buckets*cf.bucketSize)
+}
+
+// Data returns the filter in the layout written by WriteTo.
+func (cf *CuckooFilter) Data() []byte {
+	data, _ := cf.MarshalBinary()
+	return data
+}
+
+// locate returns the non-zero fingerprint of val and its two candidate buckets.
+func (cf *CuckooFilter) locate(val []byte) (uint64, uint64, uint64) {
+	h1, h2 := hash.Murmur3Sum128(val, 0)
+	fp := h2 & (1<<cf.fingerprintBits - 1)
+	if fp == 0 {
+		fp = 1
+	}
+	i1 := h1 & (cf.buckets - 1)
+	return fp, i1, cf.altIndex(i1, fp)
+}
+
+// altIndex maps a bucket to the other candidate bucket of fp. It is its own inverse.
+func (cf *CuckooFilter) altIndex(i, fp uint64) uint64 {
+	return (i ^ fp*altIndexMul) & (cf.buckets - 1)
+}
+
+// find returns the position of fp in bucket i, or bucketSize if it is not there.
+func (cf *CuckooFilter) find(i, fp uint64) uint64 {
+	for j := uint64(0); j < cf.bucketSize; j++ {
+		if cf.slot(i*cf.bucketSize+j) == fp {
+			return j
+		}
+	}
+	return cf.bucketSize
+}
+
+func (cf *CuckooFilter) insertInto(i, fp uint64) bool {
+	if j := cf.find(i, 0); j < cf.bucketSize {
+		cf.setSlot(i*cf.bucketSize+j, fp)
+		return true
+	}
+	return false
+}
+
+// slot reads the fingerprint in the packed slot array, which may straddle two words.
+func (cf *CuckooFilter) slot(n uint64) uint64 {
+	pos := n * cf.fingerprintBits
+	word, offset := pos/64, pos%64
+	v := cf.slots[word] >> offset
+	if offset+cf.fingerprintBits > 64 {
+		v |= cf.slots[word+1] << (64 - offset)
+	}
+	return v & (1<<cf.fingerprintBits - 1)
+}
+
+func (cf *CuckooFilter) setSlot(n uint64, fp uint64) {
+	mask := uint64(1)<<cf.fingerprintBits - 1
+	pos := n * cf.fingerprintBits
+	word, offset := pos/64, pos%64
+	cf.slots[word] = cf.slots[word]&^(mask<<offset) | fp<<offset
+	if offset+cf.fingerprintBits > 64 {
+		shift := 64 - offset
+		cf.slots[word+1] = cf.slots[word+1]&^(mask>>shift) | fp>>shift
+	}
+}
@@ -0,0 +1,138 @@
+package cuckoofilter
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCuckooFilter(t *testing.T) {
+	cf := New(1024, WithGoroutineSafe())
+	assert.False(t, cf.Lookup("hello"))
+	assert.Nil(t, cf.Insert("hello"))
+	assert.True(t, cf.Lookup("hello"))
+	assert.Equal(t, uint64(1), cf.Count())
+
+	assert.True(t, cf.Delete("hello"))
+	assert.False(t, cf.Lookup("hello"))
+	assert.False(t, cf.Delete("hello"))
+	assert.Equal(t, uint64(0), cf.Count())
+
+	// duplicates are stored once per insertion
+	assert.Nil(t, cf.InsertBytes([]byte("a")))
+	assert.Nil(t, cf.InsertBytes([]byte("a")))
+	assert.True(t, cf.DeleteBytes([]byte("a")))
+	assert.True(t, cf.LookupBytes([]byte("a")))
+	assert.True(t, cf.DeleteBytes([]byte("a")))
+	assert.False(t, cf.LookupBytes([]byte("a")))
+}
+
+func TestFingerprintAndBucketSize(t *testing.T) {
+	for _, fingerprintBits := range []uint64{4, 7, 12, 16, 23, 32} {
+		for _, bucketSize := range []uint64{1, 2, 4, 8} {
+			cf := New(2000, WithFingerprintBits(fingerprintBits), WithBucketSize(bucketSize))
+			inserted := make([]string, 0)
+			for i := 0; i < 1000; i++ {
+				if cf.Insert(strconv.Itoa(i)) == nil {
+					inserted = append(inserted, strconv.Itoa(i))
+				}
+			}
+			assert.Equal(t, uint64(len(inserted)), cf.Count())
+			for _, val := range inserted {
+				assert.True(t, cf.Lookup(val))
+			}
+			for _, val := range inserted {
+				assert.True(t, cf.Delete(val))
+			}
+			assert.Equal(t, uint64(0), cf.Count())
+		}
+	}
+	assert.Panics(t, func() { New(10, WithFingerprintBits(33)) })
+	assert.Panics(t, func() { New(10, WithBucketSize(0)) })
+	assert.Panics(t, func() { New(10, WithMaxKicks(-1)) })
+	assert.NotPanics(t, func() { New(10, WithMaxKicks(0)) })
+
+	// NewWithEstimates leaves the spare capacity of the caller's options alone
+	opts := make([]Option, 1, 2)
+	opts[0] = WithBucketSize(2)
+	spare := opts[:2]
+	NewWithEstimates(100, 0.01, opts...)
+	assert.Nil(t, spare[1])
+}
+
+func TestFalsePositiveRate(t *testing.T) {
+	cf := NewWithEstimates(10000, 0.001)
+	for i := 0; i < 10000; i++ {
+		assert.Nil(t, cf.Insert(strconv.Itoa(i)))
+	}
+	fp := 0
+	for i := 10000; i < 110000; i++ {
+		if cf.Lookup(strconv.Itoa(i)) {
+			fp++
+		}
+	}
+	assert.Less(t, fp, 200)
+}
+
+func TestFull(t *testing.T) {
+	cf := New(1024, WithMaxKicks(100))
+	n := 0
+	var err error
+	for ; n < 2048; n++ {
+		if err = cf.Insert(strconv.Itoa(n)); err != nil {
+			break
+		}
+	}
+	assert.True(t, errors.Is(err, ErrFull))
+	assert.Greater(t, cf.LoadFactor(), 0.9)
+	// a failed insertion loses nothing
+	assert.Equal(t, uint64(n), cf.Count())
+	for i := 0; i < n; i++ {
+		assert.True(t, cf.Lookup(strconv.Itoa(i)))
+	}
+	assert.True(t, cf.Delete("0"))
+	assert.Nil(t, cf.Insert("0"))
+}
+
+func TestSerialization(t *testing.T) {
+	cf := New(1000, WithFingerprintBits(12), WithBucketSize(2))
+	for i := 0; i < 500; i++ {
+		cf.Insert(strconv.Itoa(i))
+	}
+
+	restored := NewFromData(cf.Data(), WithGoroutineSafe())
+	assert.NotNil(t, restored)
+	assert.Equal(t, cf.Count(), restored.Count())
+	for i := 0; i < 500; i++ {
+		assert.True(t, restored.Lookup(strconv.Itoa(i)))
+	}
+
+	buf := new(bytes.Buffer)
+	n, err := cf.WriteTo(buf)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(buf.Len()), n)
+	other := New(1)
+	read, err := other.ReadFrom(buf)
+	assert.Nil(t, err)
+	assert.Equal(t, n, read)
+	assert.Equal(t, cf.Data(), other.Data())
+}
+
+func TestCorruptedData(t *testing.T) {
+	cf := New(100)
+	cf.Insert("hello")
+	data := cf.Data()
+
+	corrupted := append([]byte{}, data...)
+	corrupted[len(corrupted)-10] ^= 1
+	assert.True(t, errors.Is(cf.UnmarshalBinary(corrupted), ErrChecksumMismatch))
+	assert.True(t, errors.Is(cf.UnmarshalBinary(data[:len(data)-1]), io.ErrUnexpectedEOF))
+	assert.True(t, errors.Is(cf.UnmarshalBinary(append(data, 0)), ErrTrailingData))
+	assert.True(t, errors.Is(cf.UnmarshalBinary([]byte("GBLF....")), ErrInvalidFormat))
+	assert.Nil(t, NewFromData(data[:20]))
+	assert.True(t, cf.Lookup("hello"))
+}
@@ -0,0 +1,137 @@
+package cuckoofilter
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"goalds/utils/serialize"
+	"hash/crc32"
+	"io"
+)
+
+// The layout written by WriteTo and MarshalBinary, little endian:
+//
+//	magic "GCKF" | version uint16 | buckets uint64 | bucket size uint64 | fingerprint bits uint64 |
+//	count uint64 | slot words uint64... | CRC32 of all previous bytes
+const (
+	serializationMagic   = "GCKF"
+	serializationVersion = uint16(1)
+)
+
+var (
+	ErrInvalidFormat    = errors.New("cuckoofilter: invalid format")
+	ErrChecksumMismatch = errors.New("cuckoofilter: checksum mismatch")
+	ErrTrailingData     = errors.New("cuckoofilter: trailing data")
+)
+
+var (
+	_ io.WriterTo                = &CuckooFilter{}
+	_ io.ReaderFrom              = &CuckooFilter{}
+	_ encoding.BinaryMarshaler   = &CuckooFilter{}
+	_ encoding.BinaryUnmarshaler = &CuckooFilter{}
+)
+
+func (cf *CuckooFilter) WriteTo(w io.Writer) (int64, error) {
+	cf.l.RLock()
+	defer cf.l.RUnlock()
+
+	cw := &serialize.CountingWriter{W: w}
+	crc := crc32.NewIEEE()
+	mw := io.MultiWriter(cw, crc)
+	buf := make([]byte, 0, 38+len(cf.slots)*8)
+	buf = append(buf, serializationMagic...)
+	buf = binary.LittleEndian.AppendUint16(buf, serializationVersion)
+	buf = binary.LittleEndian.AppendUint64(buf, cf.buckets)
+	buf = binary.LittleEndian.AppendUint64(buf, cf.bucketSize)
+	buf = binary.LittleEndian.AppendUint64(buf, cf.fingerprintBits)
+	buf = binary.LittleEndian.AppendUint64(buf, cf.count)
+	for _, word := range cf.slots {
+		buf = binary.LittleEndian.AppendUint64(buf, word)
+	}
+	if _, err := mw.Write(buf); err != nil {
+		return cw.N, err
+	}
+	err := binary.Write(cw, binary.LittleEndian, crc.Sum32())
+	return cw.N, err
+}
+
+// ReadFrom replaces the filter with one read from r, keeping its locker and relocation bound.
+// On error cf is left untouched.
+func (cf *CuckooFilter) ReadFrom(r io.Reader) (int64, error) {
+	cr := &serialize.CountingReader{R: r}
+	crc := crc32.NewIEEE()
+	tr := io.TeeReader(cr, crc)
+
+	magic := make([]byte, len(serializationMagic))
+	if _, err := io.ReadFull(tr, magic); err != nil {
+		return cr.N, serialize.Truncated("cuckoofilter", err)
+	}
+	if string(magic) != serializationMagic {
+		return cr.N, ErrInvalidFormat
+	}
+	var version uint16
+	if err := binary.Read(tr, binary.LittleEndian, &version); err != nil {
+		return cr.N, serialize.Truncated("cuckoofilter", err)
+	}
+	if version != serializationVersion {
+		return cr.N, fmt.Errorf("cuckoofilter: unsupported format version %d", version)
+	}
+	var header [4]uint64
+	if err := binary.Read(tr, binary.LittleEndian, &header); err != nil {
+		return cr.N, serialize.Truncated("cuckoofilter", err)
+	}
+	buckets, bucketSize, fingerprintBits, count := header[0], header[1], header[2], header[3]
+	if buckets == 0 || buckets&(buckets-1) != 0 || bucketSize == 0 || fingerprintBits == 0 || fingerprintBits > 32 {
+		return cr.N, fmt.Errorf("cuckoofilter: invalid parameters buckets=%d bucket size=%d fingerprint bits=%d",
+			buckets, bucketSize, fingerprintBits)
+	}
+	slots := buckets * bucketSize
+	if slots/bucketSize != buckets || count > slots || slots*fingerprintBits/fingerprintBits != slots {
+		return cr.N, ErrInvalidFormat
+	}
+	size := int64((slots*fingerprintBits + 63) / 64 * 8)
+	if size <= 0 {
+		return cr.N, ErrInvalidFormat
+	}
+	data, err := serialize.ReadN(tr, size)
+	if err != nil {
+		return cr.N, serialize.Truncated("cuckoofilter", err)
+	}
+	sum := crc.Sum32()
+	var expected uint32
+	if err := binary.Read(cr, binary.LittleEndian, &expected); err != nil {
+		return cr.N, serialize.Truncated("cuckoofilter", err)
+	}
+	if sum != expected {
+		return cr.N, ErrChecksumMismatch
+	}
+
+	words := make([]uint64, size/8)
+	for i := range words {
+		words[i] = binary.LittleEndian.Uint64(data[i*8:])
+	}
+	cf.l.Lock()
+	defer cf.l.Unlock()
+	cf.buckets, cf.bucketSize, cf.fingerprintBits = buckets, bucketSize, fingerprintBits
+	cf.count, cf.slots = count, words
+	return cr.N, nil
+}
+
+func (cf *CuckooFilter) MarshalBinary() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	_, err := cf.WriteTo(buf)
+	return buf.Bytes(), err
+}
+
+func (cf *CuckooFilter) UnmarshalBinary(data []byte) error {
+	reader := bytes.NewReader(data)
+	if _, err := cf.ReadFrom(reader); err != nil {
+		return err
+	}
+	if reader.Len() > 0 {
+		return ErrTrailingData
+	}
+	return nil
+}
@@ -0,0 +1,239 @@
+package memtable
+
+import (
+	"bytes"
+	"goalds/ds/skiplist"
+	"goalds/utils/locker"
+	"sync"
+)
+
+// entryOverhead approximates the bytes a skiplist element costs besides its key and value:
+// the element itself, its internal key header and an average tower of two levels.
+const entryOverhead = 96
+
+var defaultLocker locker.FakeLocker
+
+type Options struct {
+	locker   locker.Locker
+	safeList bool
+}
+
+type Option func(option *Options)
+
+// WithGoroutineSafe lets readers, including snapshots and iterators, run while writers keep inserting.
+func WithGoroutineSafe() Option {
+	return func(option *Options) {
+		option.locker = &sync.RWMutex{}
+		option.safeList = true
+	}
+}
+
+type kind uint8
+
+const (
+	kindValue kind = iota
+	kindDeletion
+)
+
+// internalKey orders entries by user key ascending, then by sequence number descending,
+// so the newest version of a key comes first.
+type internalKey struct {
+	userKey []byte
+	seq     uint64
+	kind    kind
+}
+
+func internalKeyCmp(a, b internalKey) int {
+	if ret := bytes.Compare(a.userKey, b.userKey); ret != 0 {
+		return ret
+	}
+	if a.seq > b.seq {
+		return -1
+	}
+	if a.seq < b.seq {
+		return 1
+	}
+	return 0
+}
+
+// MemTable is an ordered in-memory table for an LSM-style store. Every write gets
+// a sequence number and never overwrites older versions, so a Snapshot keeps seeing
+// the table as it was when the snapshot was taken.
+type MemTable struct {
+	locker locker.Locker
+	sl     *skiplist.SkipList[internalKey, []byte]
+	seq    uint64
+	memory int
+}
+
+func New(options ...Option) *MemTable {
+	opt := &Options{locker: defaultLocker}
+	for _, option := range options {
+		option(opt)
+	}
+	slOpts := make([]skiplist.Option, 0)
+	if opt.safeList {
+		slOpts = append(slOpts, skiplist.WithGoroutineSafe())
+	}
+	return &MemTable{
+		locker: opt.locker,
+		sl:     skiplist.New[internalKey, []byte](internalKeyCmp, slOpts...),
+	}
+}
+
+// Put stores a copy of key and value and returns the sequence number of the write.
+func (mt *MemTable) Put(key, value []byte) uint64 {
+	return mt.add(key, value, kindValue)
+}
+
+// Delete writes a tombstone for key and returns the sequence number of the write.
+func (mt *MemTable) Delete(key []byte) uint64 {
+	return mt.add(key, nil, kindDeletion)
+}
+
+// Get returns the newest value of key. The returned slice must not be modified.
+func (mt *MemTable) Get(key []byte) ([]byte, error) {
+	return mt.Snapshot().Get(key)
+}
+
+// Seq returns the sequence number of the last write.
+func (mt *MemTable) Seq() uint64 {
+	mt.locker.RLock()
+	defer mt.locker.RUnlock()
+
+	return mt.seq
+}
+
+// Len returns the number of entries, counting every version and tombstone.
+func (mt *MemTable) Len() int {
+	return mt.sl.Len()
+}
+
+// ApproximateMemoryUsage estimates the bytes held by the table, to decide when to flush it.
+func (mt *MemTable) ApproximateMemoryUsage() int {
+	mt.locker.RLock()
+	defer mt.locker.RUnlock()
+
+	return mt.memory
+}
+
+// Snapshot returns a consistent view of the table that ignores all later writes.
+func (mt *MemTable) Snapshot() *Snapshot {
+	mt.locker.RLock()
+	defer mt.locker.RUnlock()
+
+	return &Snapshot{mt: mt, seq: mt.seq}
+}
+
+// Iterator returns an iterator over the newest live version of every key.
+func (mt *MemTable) Iterator() *Iterator {
+	return mt.Snapshot().Iterator()
+}
+
+func (mt *MemTable) add(key, value []byte, k kind) uint64 {
+	mt.locker.Lock()
+	defer mt.locker.Unlock()
+
+	mt.seq++
+	ik := internalKey{
+		userKey: append([]byte(nil), key...),
+		seq:     mt.seq,
+		kind:    k,
+	}
+	mt.sl.Insert(ik, append([]byte(nil), value...))
+	mt.memory += len(key) + len(value) + entryOverhead
+	return mt.seq
+}
+
+// Snapshot is a read-only view of a MemTable at a sequence number.
+type Snapshot struct {
+	mt  *MemTable
+	seq uint64
+}
+
+func (s *Snapshot) Seq() uint64 {
+	return s.seq
+}
+
+// Get returns the newest value of key written at or before the snapshot.
+func (s *Snapshot) Get(key []byte) ([]byte, error) {
+	it := s.mt.sl.Iterator()
+	if !it.Seek(internalKey{userKey: key, seq: s.seq}) {
+		return nil, skiplist.ErrNotFound
+	}
+	ik := it.Key()
+	if !bytes.Equal(ik.userKey, key) || ik.kind == kindDeletion {
+		return nil, skiplist.ErrNotFound
+	}
+	return it.Value(), nil
+}
+
+// Iterator returns an iterator over the newest live version of every key visible to the snapshot.
+func (s *Snapshot) Iterator() *Iterator {
+	return &Iterator{
+		it:  s.mt.sl.Iterator(),
+		seq: s.seq,
+	}
+}
+
+// Iterator walks the keys visible to a snapshot in ascending order,
+// yielding only the newest version of each key and skipping deleted keys.
+type Iterator struct {
+	it  *skiplist.Iterator[internalKey, []byte]
+	seq uint64
+}
+
+func (it *Iterator) Valid() bool {
+	return it.it.Valid()
+}
+
+func (it *Iterator) SeekToFirst() bool {
+	it.it.SeekToFirst()
+	return it.findVisible(nil, false)
+}
+
+// Seek moves the iterator to the first visible key >= key.
+func (it *Iterator) Seek(key []byte) bool {
+	it.it.Seek(internalKey{userKey: key, seq: it.seq})
+	return it.findVisible(nil, false)
+}
+
+func (it *Iterator) Next() bool {
+	if !it.it.Valid() {
+		return false
+	}
+	key := it.it.Key().userKey
+	it.it.Next()
+	return it.findVisible(key, true)
+}
+
+// Key returns the current key. The returned slice must not be modified.
+func (it *Iterator) Key() []byte {
+	return it.it.Key().userKey
+}
+
+// Value returns the current value. The returned slice must not be modified.
+func (it *Iterator) Value() []byte {
+	return it.it.Value()
+}
+
+// findVisible advances the underlying iterator to the newest version, visible to the
+// snapshot, of the next key that is not deleted. If skipping is set, remaining versions
+// of skipKey are passed over first.
+func (it *Iterator) findVisible(skipKey []byte, skipping bool) bool {
+	for ; it.it.Valid(); it.it.Next() {
+		ik := it.it.Key()
+		if ik.seq > it.seq {
+			continue
+		}
+		if skipping && bytes.Equal(ik.userKey, skipKey) {
+			continue
+		}
+		if ik.kind == kindDeletion {
+			skipKey, skipping = ik.userKey, true
+			continue
+		}
+		return true
+	}
+	return false
+}
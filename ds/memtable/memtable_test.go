@@ -0,0 +1,132 @@
+package memtable
+
+import (
+	"fmt"
+	"goalds/ds/skiplist"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func entries(it *Iterator) []string {
+	ret := make([]string, 0)
+	for ok := it.SeekToFirst(); ok; ok = it.Next() {
+		ret = append(ret, string(it.Key())+"="+string(it.Value()))
+	}
+	return ret
+}
+
+func TestPutGetDelete(t *testing.T) {
+	mt := New()
+	_, err := mt.Get([]byte("a"))
+	assert.Equal(t, skiplist.ErrNotFound, err)
+
+	assert.Equal(t, uint64(1), mt.Put([]byte("a"), []byte("1")))
+	assert.Equal(t, uint64(2), mt.Put([]byte("b"), []byte("2")))
+	assert.Equal(t, uint64(3), mt.Put([]byte("a"), []byte("3")))
+	v, err := mt.Get([]byte("a"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("3"), v)
+
+	key := []byte("c")
+	mt.Put(key, []byte("4"))
+	key[0] = 'z'
+	v, _ = mt.Get([]byte("c"))
+	assert.Equal(t, []byte("4"), v)
+
+	assert.Equal(t, uint64(5), mt.Delete([]byte("b")))
+	_, err = mt.Get([]byte("b"))
+	assert.Equal(t, skiplist.ErrNotFound, err)
+	assert.Equal(t, uint64(5), mt.Seq())
+	assert.Equal(t, 5, mt.Len())
+	assert.Equal(t, []string{"a=3", "c=4"}, entries(mt.Iterator()))
+
+	mt.Put([]byte("b"), []byte("6"))
+	assert.Equal(t, []string{"a=3", "b=6", "c=4"}, entries(mt.Iterator()))
+}
+
+func TestSnapshot(t *testing.T) {
+	mt := New()
+	mt.Put([]byte("a"), []byte("1"))
+	mt.Put([]byte("b"), []byte("1"))
+	s1 := mt.Snapshot()
+	mt.Put([]byte("a"), []byte("2"))
+	mt.Delete([]byte("b"))
+	mt.Put([]byte("c"), []byte("2"))
+	s2 := mt.Snapshot()
+	mt.Delete([]byte("a"))
+
+	assert.Equal(t, []string{"a=1", "b=1"}, entries(s1.Iterator()))
+	assert.Equal(t, []string{"a=2", "c=2"}, entries(s2.Iterator()))
+	assert.Equal(t, []string{"c=2"}, entries(mt.Iterator()))
+
+	v, err := s1.Get([]byte("b"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("1"), v)
+	_, err = s1.Get([]byte("c"))
+	assert.Equal(t, skiplist.ErrNotFound, err)
+	v, _ = s2.Get([]byte("a"))
+	assert.Equal(t, []byte("2"), v)
+
+	it := s2.Iterator()
+	assert.True(t, it.Seek([]byte("b")))
+	assert.Equal(t, []byte("c"), it.Key())
+	assert.False(t, it.Next())
+	assert.False(t, it.Valid())
+	assert.True(t, it.Seek([]byte("")))
+	assert.Equal(t, []byte("a"), it.Key())
+}
+
+func TestMemoryUsage(t *testing.T) {
+	mt := New()
+	assert.Equal(t, 0, mt.ApproximateMemoryUsage())
+	mt.Put([]byte("key"), []byte("value"))
+	first := mt.ApproximateMemoryUsage()
+	assert.True(t, first > 8)
+	mt.Put([]byte("key"), make([]byte, 1000))
+	assert.True(t, mt.ApproximateMemoryUsage() >= first+1000)
+	mt.Delete([]byte("key"))
+	assert.True(t, mt.ApproximateMemoryUsage() > first+1000)
+}
+
+func TestConcurrentReaders(t *testing.T) {
+	mt := New(WithGoroutineSafe())
+	for i := 0; i < 100; i++ {
+		mt.Put([]byte(fmt.Sprintf("key%03d", i)), []byte("0"))
+	}
+	snapshot := mt.Snapshot()
+
+	wg := sync.WaitGroup{}
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for round := 1; round <= 20; round++ {
+			for i := 0; i < 100; i++ {
+				key := []byte(fmt.Sprintf("key%03d", i))
+				if i%10 == round%10 {
+					mt.Delete(key)
+				} else {
+					mt.Put(key, []byte(fmt.Sprint(round)))
+				}
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for round := 0; round < 20; round++ {
+			got := entries(snapshot.Iterator())
+			assert.Equal(t, 100, len(got))
+			for _, e := range got {
+				assert.Equal(t, "0", e[len(e)-1:])
+			}
+		}
+	}()
+	wg.Wait()
+
+	got := entries(mt.Iterator())
+	assert.Equal(t, 90, len(got))
+	for _, e := range got {
+		assert.Equal(t, "=20", e[len(e)-3:])
+	}
+}
@@ -0,0 +1,460 @@
+package roaring
+
+import (
+	"math/bits"
+	"sort"
+)
+
+const (
+	// arrayMaxSize is the largest cardinality kept in an array container. Above it a
+	// bitmap container, always 8 KB, is smaller than 2 bytes per value.
+	arrayMaxSize = 4096
+	bitmapWords  = 1024
+)
+
+// container holds the low 16 bits of the values sharing the same high 16 bits.
+// add and remove return the container to use from then on, which may be
+// of another type when the cardinality crosses arrayMaxSize.
+type container interface {
+	contains(x uint16) bool
+	add(x uint16) (container, bool)
+	remove(x uint16) (container, bool)
+	count() int
+	// runs returns the number of runs of consecutive values.
+	runs() int
+	traversal(visitor func(x uint16) bool) bool
+	// toBitmap returns the values as a bitmap container, never sharing memory with the receiver.
+	toBitmap() *bitmapContainer
+	clone() container
+}
+
+// arrayContainer is a sorted array, for at most arrayMaxSize values.
+type arrayContainer struct {
+	values []uint16
+}
+
+func (ac *arrayContainer) search(x uint16) int {
+	return sort.Search(len(ac.values), func(i int) bool { return ac.values[i] >= x })
+}
+
+func (ac *arrayContainer) contains(x uint16) bool {
+	i := ac.search(x)
+	return i < len(ac.values) && ac.values[i] == x
+}
+
+func (ac *arrayContainer) add(x uint16) (container, bool) {
+	i := ac.search(x)
+	if i < len(ac.values) && ac.values[i] == x {
+		return ac, false
+	}
+	if len(ac.values) >= arrayMaxSize {
+		bc := ac.toBitmap()
+		bc.add(x)
+		return bc, true
+	}
+	ac.values = append(ac.values, 0)
+	copy(ac.values[i+1:], ac.values[i:])
+	ac.values[i] = x
+	return ac, true
+}
+
+func (ac *arrayContainer) remove(x uint16) (container, bool) {
+	i := ac.search(x)
+	if i == len(ac.values) || ac.values[i] != x {
+		return ac, false
+	}
+	ac.values = append(ac.values[:i], ac.values[i+1:]...)
+	return ac, true
+}
+
+func (ac *arrayContainer) count() int {
+	return len(ac.values)
+}
+
+func (ac *arrayContainer) runs() int {
+	runs := 0
+	for i, v := range ac.values {
+		if i == 0 || ac.values[i-1]+1 != v {
+			runs++
+		}
+	}
+	return runs
+}
+
+func (ac *arrayContainer) traversal(visitor func(x uint16) bool) bool {
+	for _, v := range ac.values {
+		if !visitor(v) {
+			return false
+		}
+	}
+	return true
+}
+
+func (ac *arrayContainer) toBitmap() *bitmapContainer {
+	bc := newBitmapContainer()
+	for _, v := range ac.values {
+		bc.words[v/64] |= 1 << (v % 64)
+	}
+	bc.card = len(ac.values)
+	return bc
+}
+
+func (ac *arrayContainer) clone() container {
+	return &arrayContainer{values: append([]uint16(nil), ac.values...)}
+}
+
+// bitmapContainer is a plain bitmap of 2^16 bits, for more than arrayMaxSize values.
+type bitmapContainer struct {
+	words []uint64
+	card  int
+}
+
+func newBitmapContainer() *bitmapContainer {
+	return &bitmapContainer{words: make([]uint64, bitmapWords)}
+}
+
+func (bc *bitmapContainer) contains(x uint16) bool {
+	return bc.words[x/64]&(1<<(x%64)) != 0
+}
+
+func (bc *bitmapContainer) add(x uint16) (container, bool) {
+	if bc.contains(x) {
+		return bc, false
+	}
+	bc.words[x/64] |= 1 << (x % 64)
+	bc.card++
+	return bc, true
+}
+
+func (bc *bitmapContainer) remove(x uint16) (container, bool) {
+	if !bc.contains(x) {
+		return bc, false
+	}
+	bc.words[x/64] &^= 1 << (x % 64)
+	bc.card--
+	return bc.normalize(), true
+}
+
+func (bc *bitmapContainer) count() int {
+	return bc.card
+}
+
+func (bc *bitmapContainer) runs() int {
+	runs := 0
+	carry := uint64(0)
+	for _, w := range bc.words {
+		// a run starts at every set bit whose lower neighbour is clear
+		runs += bits.OnesCount64(w &^ (w<<1 | carry))
+		carry = w >> 63
+	}
+	return runs
+}
+
+func (bc *bitmapContainer) traversal(visitor func(x uint16) bool) bool {
+	for i, w := range bc.words {
+		for ; w != 0; w &= w - 1 {
+			if !visitor(uint16(i*64 + bits.TrailingZeros64(w))) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (bc *bitmapContainer) toBitmap() *bitmapContainer {
+	return bc.clone().(*bitmapContainer)
+}
+
+func (bc *bitmapContainer) clone() container {
+	return &bitmapContainer{words: append([]uint64(nil), bc.words...), card: bc.card}
+}
+
+func (bc *bitmapContainer) computeCard() {
+	bc.card = 0
+	for _, w := range bc.words {
+		bc.card += bits.OnesCount64(w)
+	}
+}
+
+// normalize returns an array container instead if the cardinality allows it.
+func (bc *bitmapContainer) normalize() container {
+	if bc.card > arrayMaxSize {
+		return bc
+	}
+	values := make([]uint16, 0, bc.card)
+	bc.traversal(func(x uint16) bool {
+		values = append(values, x)
+		return true
+	})
+	return &arrayContainer{values: values}
+}
+
+// interval is a run of consecutive values from start to last, inclusive.
+type interval struct {
+	start uint16
+	last  uint16
+}
+
+// runContainer is a sorted list of disjoint, non-adjacent runs. It is only produced
+// by RunOptimize and by deserialization, and stays a run container when modified.
+type runContainer struct {
+	intervals []interval
+}
+
+// search returns the index of the first interval starting after x.
+func (rc *runContainer) search(x uint16) int {
+	return sort.Search(len(rc.intervals), func(i int) bool { return rc.intervals[i].start > x })
+}
+
+func (rc *runContainer) contains(x uint16) bool {
+	i := rc.search(x)
+	return i > 0 && x <= rc.intervals[i-1].last
+}
+
+func (rc *runContainer) add(x uint16) (container, bool) {
+	i := rc.search(x)
+	if i > 0 && x <= rc.intervals[i-1].last {
+		return rc, false
+	}
+	joinPrev := i > 0 && int(rc.intervals[i-1].last)+1 == int(x)
+	joinNext := i < len(rc.intervals) && int(x)+1 == int(rc.intervals[i].start)
+	switch {
+	case joinPrev && joinNext:
+		rc.intervals[i-1].last = rc.intervals[i].last
+		rc.intervals = append(rc.intervals[:i], rc.intervals[i+1:]...)
+	case joinPrev:
+		rc.intervals[i-1].last = x
+	case joinNext:
+		rc.intervals[i].start = x
+	default:
+		rc.intervals = append(rc.intervals, interval{})
+		copy(rc.intervals[i+1:], rc.intervals[i:])
+		rc.intervals[i] = interval{start: x, last: x}
+	}
+	return rc, true
+}
+
+func (rc *runContainer) remove(x uint16) (container, bool) {
+	i := rc.search(x) - 1
+	if i < 0 || x > rc.intervals[i].last {
+		return rc, false
+	}
+	iv := rc.intervals[i]
+	switch {
+	case iv.start == iv.last:
+		rc.intervals = append(rc.intervals[:i], rc.intervals[i+1:]...)
+	case x == iv.start:
+		rc.intervals[i].start++
+	case x == iv.last:
+		rc.intervals[i].last--
+	default:
+		rc.intervals = append(rc.intervals, interval{})
+		copy(rc.intervals[i+2:], rc.intervals[i+1:])
+		rc.intervals[i].last = x - 1
+		rc.intervals[i+1] = interval{start: x + 1, last: iv.last}
+	}
+	return rc, true
+}
+
+func (rc *runContainer) count() int {
+	count := 0
+	for _, iv := range rc.intervals {
+		count += int(iv.last-iv.start) + 1
+	}
+	return count
+}
+
+func (rc *runContainer) runs() int {
+	return len(rc.intervals)
+}
+
+func (rc *runContainer) traversal(visitor func(x uint16) bool) bool {
+	for _, iv := range rc.intervals {
+		for v := int(iv.start); v <= int(iv.last); v++ {
+			if !visitor(uint16(v)) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (rc *runContainer) toBitmap() *bitmapContainer {
+	bc := newBitmapContainer()
+	for _, iv := range rc.intervals {
+		lo, hi := int(iv.start), int(iv.last)
+		for w := lo / 64; w <= hi/64; w++ {
+			mask := ^uint64(0)
+			if w == lo/64 {
+				mask &= ^uint64(0) << (lo % 64)
+			}
+			if w == hi/64 {
+				mask &= ^uint64(0) >> (63 - hi%64)
+			}
+			bc.words[w] |= mask
+		}
+	}
+	bc.card = rc.count()
+	return bc
+}
+
+func (rc *runContainer) clone() container {
+	return &runContainer{intervals: append([]interval(nil), rc.intervals...)}
+}
+
+func newRunContainer(c container) *runContainer {
+	rc := &runContainer{intervals: make([]interval, 0, c.runs())}
+	c.traversal(func(x uint16) bool {
+		n := len(rc.intervals)
+		if n > 0 && int(rc.intervals[n-1].last)+1 == int(x) {
+			rc.intervals[n-1].last = x
+		} else {
+			rc.intervals = append(rc.intervals, interval{start: x, last: x})
+		}
+		return true
+	})
+	return rc
+}
+
+// arraySize, runSize and bitmapSize are the bytes each kind of container takes in the portable format.
+func arraySize(card int) int {
+	return 2 * card
+}
+
+func runSize(runs int) int {
+	return 2 + 4*runs
+}
+
+const bitmapSize = bitmapWords * 8
+
+// optimize returns the smallest representation of c, as counted by the portable format.
+// An array or bitmap is chosen by cardinality, a run container only if it is smaller still.
+func optimize(c container) container {
+	card := c.count()
+	size := bitmapSize
+	if card <= arrayMaxSize {
+		size = arraySize(card)
+	}
+	runs := c.runs()
+	if runSize(runs) < size {
+		if rc, ok := c.(*runContainer); ok {
+			return rc
+		}
+		return newRunContainer(c)
+	}
+	if _, ok := c.(*runContainer); !ok {
+		return c
+	}
+	return c.toBitmap().normalize()
+}
+
+func and(a, b container) container {
+	aa, aIsArray := a.(*arrayContainer)
+	ba, bIsArray := b.(*arrayContainer)
+	switch {
+	case aIsArray && bIsArray:
+		values := make([]uint16, 0)
+		for i, j := 0, 0; i < len(aa.values) && j < len(ba.values); {
+			switch {
+			case aa.values[i] < ba.values[j]:
+				i++
+			case aa.values[i] > ba.values[j]:
+				j++
+			default:
+				values = append(values, aa.values[i])
+				i++
+				j++
+			}
+		}
+		return &arrayContainer{values: values}
+	case aIsArray:
+		return filter(aa, b, true)
+	case bIsArray:
+		return filter(ba, a, true)
+	}
+	return bitmapOp(a, b, func(x, y uint64) uint64 { return x & y })
+}
+
+func or(a, b container) container {
+	aa, aIsArray := a.(*arrayContainer)
+	ba, bIsArray := b.(*arrayContainer)
+	if aIsArray && bIsArray && len(aa.values)+len(ba.values) <= arrayMaxSize {
+		values := make([]uint16, 0, len(aa.values)+len(ba.values))
+		i, j := 0, 0
+		for i < len(aa.values) && j < len(ba.values) {
+			switch {
+			case aa.values[i] < ba.values[j]:
+				values = append(values, aa.values[i])
+				i++
+			case aa.values[i] > ba.values[j]:
+				values = append(values, ba.values[j])
+				j++
+			default:
+				values = append(values, aa.values[i])
+				i++
+				j++
+			}
+		}
+		values = append(values, aa.values[i:]...)
+		values = append(values, ba.values[j:]...)
+		return &arrayContainer{values: values}
+	}
+	return bitmapOp(a, b, func(x, y uint64) uint64 { return x | y })
+}
+
+func xor(a, b container) container {
+	aa, aIsArray := a.(*arrayContainer)
+	ba, bIsArray := b.(*arrayContainer)
+	if aIsArray && bIsArray && len(aa.values)+len(ba.values) <= arrayMaxSize {
+		values := make([]uint16, 0, len(aa.values)+len(ba.values))
+		i, j := 0, 0
+		for i < len(aa.values) && j < len(ba.values) {
+			switch {
+			case aa.values[i] < ba.values[j]:
+				values = append(values, aa.values[i])
+				i++
+			case aa.values[i] > ba.values[j]:
+				values = append(values, ba.values[j])
+				j++
+			default:
+				i++
+				j++
+			}
+		}
+		values = append(values, aa.values[i:]...)
+		values = append(values, ba.values[j:]...)
+		return &arrayContainer{values: values}
+	}
+	return bitmapOp(a, b, func(x, y uint64) uint64 { return x ^ y })
+}
+
+func andNot(a, b container) container {
+	if aa, ok := a.(*arrayContainer); ok {
+		return filter(aa, b, false)
+	}
+	return bitmapOp(a, b, func(x, y uint64) uint64 { return x &^ y })
+}
+
+// filter returns the values of ac that other contains, or does not contain if keep is false.
+func filter(ac *arrayContainer, other container, keep bool) container {
+	values := make([]uint16, 0)
+	for _, v := range ac.values {
+		if other.contains(v) == keep {
+			values = append(values, v)
+		}
+	}
+	return &arrayContainer{values: values}
+}
+
+func bitmapOp(a, b container, op func(x, y uint64) uint64) container {
+	ret := a.toBitmap()
+	other, ok := b.(*bitmapContainer)
+	if !ok {
+		other = b.toBitmap()
+	}
+	for i := range ret.words {
+		ret.words[i] = op(ret.words[i], other.words[i])
+	}
+	ret.computeCard()
+	return ret.normalize()
+}
@@ -0,0 +1,288 @@
+package roaring
+
+import (
+	"goalds/utils/locker"
+	"goalds/utils/visitor"
+	"sort"
+	"sync"
+)
+
+var defaultLocker locker.FakeLocker
+
+type Options struct {
+	locker locker.Locker
+}
+
+type Option func(option *Options)
+
+func WithGoroutineSafe() Option {
+	return func(option *Options) {
+		option.locker = &sync.RWMutex{}
+	}
+}
+
+// Bitmap is a compressed set of uint32 values (Chambi et al., 2016). Values are grouped by
+// their high 16 bits into containers holding the low 16 bits: a sorted array while a
+// container holds at most 4096 values, a 2^16-bit bitmap above that, and, after
+// RunOptimize, a list of runs when that is smaller still.
+type Bitmap struct {
+	locker     locker.Locker
+	keys       []uint16
+	containers []container
+}
+
+func New(options ...Option) *Bitmap {
+	opt := &Options{locker: defaultLocker}
+	for _, option := range options {
+		option(opt)
+	}
+	return &Bitmap{locker: opt.locker}
+}
+
+// NewFromValues returns a bitmap holding values.
+func NewFromValues(values ...uint32) *Bitmap {
+	b := New()
+	for _, v := range values {
+		b.Add(v)
+	}
+	return b
+}
+
+// Add inserts x and reports whether it was not present yet.
+func (b *Bitmap) Add(x uint32) bool {
+	b.locker.Lock()
+	defer b.locker.Unlock()
+
+	key, low := uint16(x>>16), uint16(x)
+	i := b.search(key)
+	if i == len(b.keys) || b.keys[i] != key {
+		b.keys = append(b.keys, 0)
+		copy(b.keys[i+1:], b.keys[i:])
+		b.keys[i] = key
+		b.containers = append(b.containers, nil)
+		copy(b.containers[i+1:], b.containers[i:])
+		b.containers[i] = &arrayContainer{values: []uint16{low}}
+		return true
+	}
+	var added bool
+	b.containers[i], added = b.containers[i].add(low)
+	return added
+}
+
+// Remove deletes x and reports whether it was present.
+func (b *Bitmap) Remove(x uint32) bool {
+	b.locker.Lock()
+	defer b.locker.Unlock()
+
+	key := uint16(x >> 16)
+	i := b.search(key)
+	if i == len(b.keys) || b.keys[i] != key {
+		return false
+	}
+	var removed bool
+	b.containers[i], removed = b.containers[i].remove(uint16(x))
+	if b.containers[i].count() == 0 {
+		b.keys = append(b.keys[:i], b.keys[i+1:]...)
+		b.containers = append(b.containers[:i], b.containers[i+1:]...)
+	}
+	return removed
+}
+
+func (b *Bitmap) Contains(x uint32) bool {
+	b.locker.RLock()
+	defer b.locker.RUnlock()
+
+	key := uint16(x >> 16)
+	i := b.search(key)
+	return i < len(b.keys) && b.keys[i] == key && b.containers[i].contains(uint16(x))
+}
+
+// Count returns the number of values, the cardinality of the set.
+func (b *Bitmap) Count() uint64 {
+	b.locker.RLock()
+	defer b.locker.RUnlock()
+
+	count := uint64(0)
+	for _, c := range b.containers {
+		count += uint64(c.count())
+	}
+	return count
+}
+
+func (b *Bitmap) Empty() bool {
+	b.locker.RLock()
+	defer b.locker.RUnlock()
+
+	return len(b.keys) == 0
+}
+
+func (b *Bitmap) Clear() {
+	b.locker.Lock()
+	defer b.locker.Unlock()
+
+	b.keys = nil
+	b.containers = nil
+}
+
+// Traversal calls visitor with every value in ascending order, until visitor returns false.
+func (b *Bitmap) Traversal(visitor visitor.KVisitor[uint32]) {
+	b.locker.RLock()
+	defer b.locker.RUnlock()
+
+	for i, c := range b.containers {
+		high := uint32(b.keys[i]) << 16
+		if !c.traversal(func(x uint16) bool { return visitor(high | uint32(x)) }) {
+			return
+		}
+	}
+}
+
+// ToArray returns the values in ascending order.
+func (b *Bitmap) ToArray() []uint32 {
+	values := make([]uint32, 0, b.Count())
+	b.Traversal(func(x uint32) bool {
+		values = append(values, x)
+		return true
+	})
+	return values
+}
+
+// Clone returns a copy of b that is not goroutine safe.
+func (b *Bitmap) Clone() *Bitmap {
+	b.locker.RLock()
+	defer b.locker.RUnlock()
+
+	ret := New()
+	ret.keys = append([]uint16(nil), b.keys...)
+	ret.containers = make([]container, len(b.containers))
+	for i, c := range b.containers {
+		ret.containers[i] = c.clone()
+	}
+	return ret
+}
+
+// Equal reports whether b and other hold the same values, whatever their containers.
+func (b *Bitmap) Equal(other *Bitmap) bool {
+	if b == other {
+		return true
+	}
+	// compare with a copy of other, so that a.Equal(b) and b.Equal(a) never wait on each other
+	other = other.Clone()
+	b.locker.RLock()
+	defer b.locker.RUnlock()
+
+	if len(b.keys) != len(other.keys) {
+		return false
+	}
+	for i, key := range b.keys {
+		if key != other.keys[i] || b.containers[i].count() != other.containers[i].count() {
+			return false
+		}
+		if xor(b.containers[i], other.containers[i]).count() != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// RunOptimize converts every container to run-length encoding where that is smaller, and
+// back where it no longer is. It reports whether any container is run-length encoded.
+func (b *Bitmap) RunOptimize() bool {
+	b.locker.Lock()
+	defer b.locker.Unlock()
+
+	hasRun := false
+	for i, c := range b.containers {
+		b.containers[i] = optimize(c)
+		if _, ok := b.containers[i].(*runContainer); ok {
+			hasRun = true
+		}
+	}
+	return hasRun
+}
+
+// And keeps only the values also in other.
+func (b *Bitmap) And(other *Bitmap) {
+	b.combine(other, and, false, false)
+}
+
+// Or adds the values of other.
+func (b *Bitmap) Or(other *Bitmap) {
+	b.combine(other, or, true, true)
+}
+
+// Xor keeps the values in exactly one of b and other.
+func (b *Bitmap) Xor(other *Bitmap) {
+	b.combine(other, xor, true, true)
+}
+
+// AndNot removes the values of other.
+func (b *Bitmap) AndNot(other *Bitmap) {
+	b.combine(other, andNot, true, false)
+}
+
+func And(a, b *Bitmap) *Bitmap {
+	ret := a.Clone()
+	ret.And(b)
+	return ret
+}
+
+func Or(a, b *Bitmap) *Bitmap {
+	ret := a.Clone()
+	ret.Or(b)
+	return ret
+}
+
+func Xor(a, b *Bitmap) *Bitmap {
+	ret := a.Clone()
+	ret.Xor(b)
+	return ret
+}
+
+func AndNot(a, b *Bitmap) *Bitmap {
+	ret := a.Clone()
+	ret.AndNot(b)
+	return ret
+}
+
+func (b *Bitmap) search(key uint16) int {
+	return sort.Search(len(b.keys), func(i int) bool { return b.keys[i] >= key })
+}
+
+// combine merges the containers of b and other by key, applying op to containers with the
+// same key. Containers with a key only in b, or only in other, are kept if keepOwn, or
+// keepOther, is set. Empty results are dropped.
+func (b *Bitmap) combine(other *Bitmap, op func(a, b container) container, keepOwn, keepOther bool) {
+	// work on a copy of other, so that b.Or(other) and other.Or(b) never wait on each other
+	other = other.Clone()
+	b.locker.Lock()
+	defer b.locker.Unlock()
+
+	keys := make([]uint16, 0, len(b.keys)+len(other.keys))
+	containers := make([]container, 0, len(b.keys)+len(other.keys))
+	i, j := 0, 0
+	for i < len(b.keys) || j < len(other.keys) {
+		switch {
+		case j == len(other.keys) || (i < len(b.keys) && b.keys[i] < other.keys[j]):
+			if keepOwn {
+				keys = append(keys, b.keys[i])
+				containers = append(containers, b.containers[i])
+			}
+			i++
+		case i == len(b.keys) || b.keys[i] > other.keys[j]:
+			if keepOther {
+				keys = append(keys, other.keys[j])
+				containers = append(containers, other.containers[j])
+			}
+			j++
+		default:
+			if c := op(b.containers[i], other.containers[j]); c.count() > 0 {
+				keys = append(keys, b.keys[i])
+				containers = append(containers, c)
+			}
+			i++
+			j++
+		}
+	}
+	b.keys, b.containers = keys, containers
+}
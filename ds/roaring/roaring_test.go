@@ -0,0 +1,346 @@
+package roaring
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math/rand"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// reference is the plain set a Bitmap is checked against.
+type reference map[uint32]bool
+
+func (r reference) sorted() []uint32 {
+	values := make([]uint32, 0, len(r))
+	for v := range r {
+		values = append(values, v)
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+	return values
+}
+
+func checkEqual(t *testing.T, expected reference, b *Bitmap) {
+	assert.Equal(t, uint64(len(expected)), b.Count())
+	assert.Equal(t, expected.sorted(), b.ToArray())
+	for v := range expected {
+		assert.True(t, b.Contains(v))
+	}
+}
+
+// randomBitmap mixes sparse keys, dense keys and long runs so that all container kinds appear.
+func randomBitmap(rander *rand.Rand) (*Bitmap, reference) {
+	b, ref := New(), reference{}
+	add := func(v uint32) {
+		b.Add(v)
+		ref[v] = true
+	}
+	for key := uint32(0); key < 6; key++ {
+		high := key << 16
+		switch rander.Intn(3) {
+		case 0:
+			for i := 0; i < 100; i++ {
+				add(high | uint32(rander.Intn(1<<16)))
+			}
+		case 1:
+			for i := 0; i < 10000; i++ {
+				add(high | uint32(rander.Intn(1<<16)))
+			}
+		default:
+			start := rander.Intn(1 << 15)
+			for v := start; v < start+rander.Intn(1<<15); v++ {
+				add(high | uint32(v))
+			}
+		}
+	}
+	return b, ref
+}
+
+func TestRoaring(t *testing.T) {
+	b := New(WithGoroutineSafe())
+	assert.True(t, b.Empty())
+	assert.True(t, b.Add(1))
+	assert.False(t, b.Add(1))
+	assert.True(t, b.Add(1<<31))
+	assert.True(t, b.Add(0xffffffff))
+	assert.True(t, b.Contains(1))
+	assert.False(t, b.Contains(2))
+	assert.Equal(t, []uint32{1, 1 << 31, 0xffffffff}, b.ToArray())
+
+	assert.True(t, b.Remove(1<<31))
+	assert.False(t, b.Remove(1<<31))
+	assert.False(t, b.Remove(12345678))
+	assert.Equal(t, uint64(2), b.Count())
+	assert.Equal(t, 2, len(b.keys))
+
+	visited := make([]uint32, 0)
+	b.Traversal(func(x uint32) bool {
+		visited = append(visited, x)
+		return false
+	})
+	assert.Equal(t, []uint32{1}, visited)
+
+	b.Clear()
+	assert.True(t, b.Empty())
+	assert.True(t, NewFromValues(3, 1, 2).Equal(NewFromValues(1, 2, 3)))
+}
+
+func TestContainerConversion(t *testing.T) {
+	b := New()
+	for i := uint32(0); i < arrayMaxSize; i++ {
+		b.Add(i * 2)
+	}
+	assert.IsType(t, &arrayContainer{}, b.containers[0])
+	b.Add(1)
+	assert.IsType(t, &bitmapContainer{}, b.containers[0])
+	b.Remove(1)
+	assert.IsType(t, &arrayContainer{}, b.containers[0])
+
+	// consecutive values are smaller as runs
+	b.Clear()
+	for i := uint32(0); i < 10000; i++ {
+		b.Add(i)
+	}
+	assert.IsType(t, &bitmapContainer{}, b.containers[0])
+	assert.True(t, b.RunOptimize())
+	assert.IsType(t, &runContainer{}, b.containers[0])
+	assert.Equal(t, uint64(10000), b.Count())
+
+	// a run container stays one when modified, and RunOptimize converts it back
+	for i := uint32(1); i < 10000; i += 2 {
+		b.Remove(i)
+	}
+	assert.IsType(t, &runContainer{}, b.containers[0])
+	assert.Equal(t, uint64(5000), b.Count())
+	assert.False(t, b.RunOptimize())
+	assert.IsType(t, &bitmapContainer{}, b.containers[0])
+}
+
+func TestRunContainer(t *testing.T) {
+	rc := &runContainer{}
+	ref := reference{}
+	rander := rand.New(rand.NewSource(1))
+	var c container = rc
+	for i := 0; i < 20000; i++ {
+		x := uint16(rander.Intn(300))
+		if rander.Intn(2) == 0 {
+			_, added := c.add(x)
+			assert.Equal(t, !ref[uint32(x)], added)
+			ref[uint32(x)] = true
+		} else {
+			_, removed := c.remove(x)
+			assert.Equal(t, ref[uint32(x)], removed)
+			delete(ref, uint32(x))
+		}
+	}
+	assert.Equal(t, len(ref), c.count())
+	for i, iv := range rc.intervals {
+		assert.LessOrEqual(t, iv.start, iv.last)
+		if i > 0 {
+			// runs never touch
+			assert.Greater(t, int(iv.start), int(rc.intervals[i-1].last)+1)
+		}
+	}
+	for x := uint16(0); x < 300; x++ {
+		assert.Equal(t, ref[uint32(x)], c.contains(x))
+		assert.Equal(t, ref[uint32(x)], rc.toBitmap().contains(x))
+	}
+
+	full := &runContainer{intervals: []interval{{start: 0, last: 0xffff}}}
+	assert.Equal(t, 1<<16, full.count())
+	assert.Equal(t, 1<<16, full.toBitmap().count())
+	_, added := full.add(0xffff)
+	assert.False(t, added)
+	full.remove(0xffff)
+	full.remove(0)
+	assert.Equal(t, []interval{{start: 1, last: 0xfffe}}, full.intervals)
+}
+
+func TestAlgebra(t *testing.T) {
+	rander := rand.New(rand.NewSource(42))
+	for round := 0; round < 20; round++ {
+		a, refA := randomBitmap(rander)
+		b, refB := randomBitmap(rander)
+		if round%2 == 0 {
+			a.RunOptimize()
+		}
+		if round%3 == 0 {
+			b.RunOptimize()
+		}
+
+		and, or, xor, andNot := reference{}, reference{}, reference{}, reference{}
+		for v := range refA {
+			or[v] = true
+			if refB[v] {
+				and[v] = true
+			} else {
+				xor[v] = true
+				andNot[v] = true
+			}
+		}
+		for v := range refB {
+			or[v] = true
+			if !refA[v] {
+				xor[v] = true
+			}
+		}
+		checkEqual(t, and, And(a, b))
+		checkEqual(t, or, Or(a, b))
+		checkEqual(t, xor, Xor(a, b))
+		checkEqual(t, andNot, AndNot(a, b))
+		// the allocating forms leave their operands alone
+		checkEqual(t, refA, a)
+		checkEqual(t, refB, b)
+		assert.True(t, Or(a, b).Equal(Or(b, a)))
+
+		a.Xor(b)
+		checkEqual(t, xor, a)
+		a.Xor(b)
+		checkEqual(t, refA, a)
+	}
+
+	a := NewFromValues(1, 2, 3)
+	a.Or(a)
+	assert.Equal(t, []uint32{1, 2, 3}, a.ToArray())
+	a.Xor(a)
+	assert.True(t, a.Empty())
+
+	// operations in both directions at once do not deadlock
+	x, y := New(WithGoroutineSafe()), New(WithGoroutineSafe())
+	x.Add(1)
+	y.Add(2)
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			x.Or(y)
+		}()
+		go func() {
+			defer wg.Done()
+			y.Or(x)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, []uint32{1, 2}, x.ToArray())
+	assert.Equal(t, []uint32{1, 2}, y.ToArray())
+
+	// comparisons in both directions run alongside writers
+	for i := 0; i < 100; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			x.Equal(y)
+		}()
+		go func() {
+			defer wg.Done()
+			y.Equal(x)
+		}()
+		go func(v uint32) {
+			defer wg.Done()
+			x.Add(v)
+			y.Add(v)
+		}(uint32(i))
+	}
+	wg.Wait()
+	assert.True(t, x.Equal(y))
+}
+
+func TestSerialization(t *testing.T) {
+	rander := rand.New(rand.NewSource(7))
+	for round := 0; round < 10; round++ {
+		b, ref := randomBitmap(rander)
+		if round%2 == 0 {
+			b.RunOptimize()
+		}
+		buf := new(bytes.Buffer)
+		n, err := b.WriteTo(buf)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(buf.Len()), n)
+
+		restored := New(WithGoroutineSafe())
+		read, err := restored.ReadFrom(buf)
+		assert.Nil(t, err)
+		assert.Equal(t, n, read)
+		checkEqual(t, ref, restored)
+		assert.True(t, b.Equal(restored))
+	}
+
+	empty, err := New().MarshalBinary()
+	assert.Nil(t, err)
+	assert.True(t, NewFromData(empty).Empty())
+}
+
+func TestPortableFormat(t *testing.T) {
+	// {1, 2, 3, 1000000} without runs: cookie and size, keys and cardinalities, offsets, arrays
+	noRun := []byte{
+		0x3a, 0x30, 0, 0, 2, 0, 0, 0,
+		0, 0, 2, 0, 15, 0, 0, 0,
+		24, 0, 0, 0, 30, 0, 0, 0,
+		1, 0, 2, 0, 3, 0,
+		0x40, 0x42,
+	}
+	b := NewFromValues(1, 2, 3, 1000000)
+	data, err := b.MarshalBinary()
+	assert.Nil(t, err)
+	assert.Equal(t, noRun, data)
+	assert.Equal(t, []uint32{1, 2, 3, 1000000}, NewFromData(noRun).ToArray())
+
+	// [0, 100) as one run: cookie with size-1, run flags, key and cardinality, no offsets, runs
+	withRun := []byte{
+		0x3b, 0x30, 0, 0, 1,
+		0, 0, 99, 0,
+		1, 0, 0, 0, 99, 0,
+	}
+	b = New()
+	for i := uint32(0); i < 100; i++ {
+		b.Add(i)
+	}
+	assert.True(t, b.RunOptimize())
+	data, err = b.MarshalBinary()
+	assert.Nil(t, err)
+	assert.Equal(t, withRun, data)
+	restored := NewFromData(withRun)
+	assert.IsType(t, &runContainer{}, restored.containers[0])
+	assert.True(t, b.Equal(restored))
+
+	// adjacent runs from other writers are merged
+	adjacent := []byte{
+		0x3b, 0x30, 0, 0, 1,
+		0, 0, 9, 0,
+		2, 0, 0, 0, 4, 0, 5, 0, 4, 0,
+	}
+	restored = NewFromData(adjacent)
+	assert.Equal(t, []interval{{start: 0, last: 9}}, restored.containers[0].(*runContainer).intervals)
+}
+
+func TestCorruptedData(t *testing.T) {
+	b := NewFromValues(1, 2, 3, 1000000)
+	data, _ := b.MarshalBinary()
+	for i := 0; i < len(data); i++ {
+		err := New().UnmarshalBinary(data[:i])
+		assert.True(t, errors.Is(err, io.ErrUnexpectedEOF), i)
+	}
+	assert.Equal(t, ErrTrailingData, New().UnmarshalBinary(append(data, 0)))
+	assert.Equal(t, ErrInvalidFormat, New().UnmarshalBinary([]byte{1, 2, 3, 4}))
+
+	unsorted := append([]byte{}, data...)
+	unsorted[24], unsorted[26] = 3, 1
+	assert.ErrorContains(t, New().UnmarshalBinary(unsorted), "not sorted")
+
+	badOffset := append([]byte{}, data...)
+	badOffset[20]++
+	assert.ErrorContains(t, New().UnmarshalBinary(badOffset), "offset")
+
+	badRun := []byte{0x3b, 0x30, 0, 0, 1, 0, 0, 99, 0, 1, 0, 0, 0, 98, 0}
+	assert.ErrorContains(t, New().UnmarshalBinary(badRun), "cardinality")
+
+	// the bitmap is left untouched on error
+	assert.NotNil(t, b.UnmarshalBinary(data[:10]))
+	assert.Equal(t, []uint32{1, 2, 3, 1000000}, b.ToArray())
+	assert.Nil(t, NewFromData(data[:10]))
+}
@@ -0,0 +1,269 @@
+package roaring
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"goalds/utils/serialize"
+	"io"
+	"math/bits"
+)
+
+// WriteTo and ReadFrom use the portable Roaring format shared by the Java, C and Go
+// implementations (https://github.com/RoaringBitmap/RoaringFormatSpec), little endian:
+//
+//	cookie | [run flags] | key and cardinality-1 of each container, uint16 each |
+//	[offset of each container, uint32] | containers
+//
+// The cookie is the uint32 12346 followed by the uint32 container count, or, when some
+// container is a run container, the uint16 12347 followed by the uint16 count-1 and a bitset
+// flagging the run containers. Offsets are omitted when there are runs and fewer than 4
+// containers. An array container is its sorted uint16 values, a bitmap container its 1024
+// uint64 words and a run container a uint16 run count followed by the uint16 start and
+// length-1 of each run. Readers tell arrays from bitmaps by the cardinality.
+const (
+	serialCookieNoRun = 12346
+	serialCookie      = 12347
+	noOffsetThreshold = 4
+)
+
+var (
+	ErrInvalidFormat = errors.New("roaring: invalid format")
+	ErrTrailingData  = errors.New("roaring: trailing data")
+)
+
+var (
+	_ io.WriterTo                = &Bitmap{}
+	_ io.ReaderFrom              = &Bitmap{}
+	_ encoding.BinaryMarshaler   = &Bitmap{}
+	_ encoding.BinaryUnmarshaler = &Bitmap{}
+)
+
+// NewFromData restores a bitmap from the portable format. It returns nil if data is
+// truncated or invalid; use UnmarshalBinary to get the reason.
+func NewFromData(data []byte, options ...Option) *Bitmap {
+	b := New(options...)
+	if err := b.UnmarshalBinary(data); err != nil {
+		return nil
+	}
+	return b
+}
+
+func (b *Bitmap) WriteTo(w io.Writer) (int64, error) {
+	b.locker.RLock()
+	defer b.locker.RUnlock()
+
+	size := len(b.keys)
+	hasRun := false
+	runFlags := make([]byte, (size+7)/8)
+	for i, c := range b.containers {
+		if _, ok := c.(*runContainer); ok {
+			hasRun = true
+			runFlags[i/8] |= 1 << (i % 8)
+		}
+	}
+
+	buf := make([]byte, 0)
+	if hasRun {
+		buf = binary.LittleEndian.AppendUint32(buf, serialCookie|uint32(size-1)<<16)
+		buf = append(buf, runFlags...)
+	} else {
+		buf = binary.LittleEndian.AppendUint32(buf, serialCookieNoRun)
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(size))
+	}
+	for i, c := range b.containers {
+		buf = binary.LittleEndian.AppendUint16(buf, b.keys[i])
+		buf = binary.LittleEndian.AppendUint16(buf, uint16(c.count()-1))
+	}
+	if !hasRun || size >= noOffsetThreshold {
+		offset := len(buf) + 4*size
+		for _, c := range b.containers {
+			buf = binary.LittleEndian.AppendUint32(buf, uint32(offset))
+			offset += serializedSize(c)
+		}
+	}
+	for _, c := range b.containers {
+		buf = appendContainer(buf, c)
+	}
+	n, err := w.Write(buf)
+	return int64(n), err
+}
+
+// ReadFrom replaces the content of b with a bitmap read from r. On error b is left untouched.
+func (b *Bitmap) ReadFrom(r io.Reader) (int64, error) {
+	cr := &serialize.CountingReader{R: r}
+
+	var cookie uint32
+	if err := binary.Read(cr, binary.LittleEndian, &cookie); err != nil {
+		return cr.N, serialize.Truncated("roaring", err)
+	}
+	var size int
+	var runFlags []byte
+	switch {
+	case cookie == serialCookieNoRun:
+		var n uint32
+		if err := binary.Read(cr, binary.LittleEndian, &n); err != nil {
+			return cr.N, serialize.Truncated("roaring", err)
+		}
+		if n > 1<<16 {
+			return cr.N, fmt.Errorf("roaring: invalid container count %d", n)
+		}
+		size = int(n)
+	case cookie&0xffff == serialCookie:
+		size = int(cookie>>16) + 1
+		runFlags = make([]byte, (size+7)/8)
+		if _, err := io.ReadFull(cr, runFlags); err != nil {
+			return cr.N, serialize.Truncated("roaring", err)
+		}
+	default:
+		return cr.N, ErrInvalidFormat
+	}
+
+	header := make([]uint16, 2*size)
+	if err := binary.Read(cr, binary.LittleEndian, header); err != nil {
+		return cr.N, serialize.Truncated("roaring", err)
+	}
+	var offsets []uint32
+	if runFlags == nil || size >= noOffsetThreshold {
+		offsets = make([]uint32, size)
+		if err := binary.Read(cr, binary.LittleEndian, offsets); err != nil {
+			return cr.N, serialize.Truncated("roaring", err)
+		}
+	}
+
+	keys := make([]uint16, size)
+	containers := make([]container, size)
+	for i := 0; i < size; i++ {
+		keys[i] = header[2*i]
+		if i > 0 && keys[i] <= keys[i-1] {
+			return cr.N, errors.New("roaring: keys are not sorted")
+		}
+		if offsets != nil && int64(offsets[i]) != cr.N {
+			return cr.N, fmt.Errorf("roaring: container %d is at offset %d, not %d", i, cr.N, offsets[i])
+		}
+		card := int(header[2*i+1]) + 1
+		isRun := runFlags != nil && runFlags[i/8]&(1<<(i%8)) != 0
+		c, err := readContainer(cr, card, isRun)
+		if err != nil {
+			return cr.N, err
+		}
+		containers[i] = c
+	}
+
+	b.locker.Lock()
+	defer b.locker.Unlock()
+	b.keys, b.containers = keys, containers
+	return cr.N, nil
+}
+
+func (b *Bitmap) MarshalBinary() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	_, err := b.WriteTo(buf)
+	return buf.Bytes(), err
+}
+
+func (b *Bitmap) UnmarshalBinary(data []byte) error {
+	reader := bytes.NewReader(data)
+	if _, err := b.ReadFrom(reader); err != nil {
+		return err
+	}
+	if reader.Len() > 0 {
+		return ErrTrailingData
+	}
+	return nil
+}
+
+func serializedSize(c container) int {
+	if rc, ok := c.(*runContainer); ok {
+		return runSize(len(rc.intervals))
+	}
+	if card := c.count(); card <= arrayMaxSize {
+		return arraySize(card)
+	}
+	return bitmapSize
+}
+
+func appendContainer(buf []byte, c container) []byte {
+	if rc, ok := c.(*runContainer); ok {
+		buf = binary.LittleEndian.AppendUint16(buf, uint16(len(rc.intervals)))
+		for _, iv := range rc.intervals {
+			buf = binary.LittleEndian.AppendUint16(buf, iv.start)
+			buf = binary.LittleEndian.AppendUint16(buf, iv.last-iv.start)
+		}
+		return buf
+	}
+	if c.count() <= arrayMaxSize {
+		c.traversal(func(x uint16) bool {
+			buf = binary.LittleEndian.AppendUint16(buf, x)
+			return true
+		})
+		return buf
+	}
+	bc, ok := c.(*bitmapContainer)
+	if !ok {
+		bc = c.toBitmap()
+	}
+	for _, w := range bc.words {
+		buf = binary.LittleEndian.AppendUint64(buf, w)
+	}
+	return buf
+}
+
+// readContainer reads a container of card values and checks that it holds exactly that many.
+func readContainer(r io.Reader, card int, isRun bool) (container, error) {
+	switch {
+	case isRun:
+		var n uint16
+		if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+			return nil, serialize.Truncated("roaring", err)
+		}
+		pairs := make([]uint16, 2*int(n))
+		if err := binary.Read(r, binary.LittleEndian, pairs); err != nil {
+			return nil, serialize.Truncated("roaring", err)
+		}
+		rc := &runContainer{intervals: make([]interval, 0, n)}
+		next := 0
+		for i := 0; i < int(n); i++ {
+			start, length := int(pairs[2*i]), int(pairs[2*i+1])
+			if start < next || start+length > 0xffff {
+				return nil, errors.New("roaring: invalid runs")
+			}
+			last := uint16(start + length)
+			if k := len(rc.intervals); k > 0 && start == next {
+				// adjacent runs are valid input, merge them to keep runs non-adjacent
+				rc.intervals[k-1].last = last
+			} else {
+				rc.intervals = append(rc.intervals, interval{start: uint16(start), last: last})
+			}
+			next = start + length + 1
+		}
+		if n == 0 || rc.count() != card {
+			return nil, errors.New("roaring: cardinality mismatch")
+		}
+		return rc, nil
+	case card <= arrayMaxSize:
+		ac := &arrayContainer{values: make([]uint16, card)}
+		if err := binary.Read(r, binary.LittleEndian, ac.values); err != nil {
+			return nil, serialize.Truncated("roaring", err)
+		}
+		for i := 1; i < card; i++ {
+			if ac.values[i] <= ac.values[i-1] {
+				return nil, errors.New("roaring: array container is not sorted")
+			}
+		}
+		return ac, nil
+	}
+	bc := newBitmapContainer()
+	if err := binary.Read(r, binary.LittleEndian, bc.words); err != nil {
+		return nil, serialize.Truncated("roaring", err)
+	}
+	for _, w := range bc.words {
+		bc.card += bits.OnesCount64(w)
+	}
+	if bc.card != card {
+		return nil, errors.New("roaring: cardinality mismatch")
+	}
+	return bc, nil
+}
@@ -0,0 +1,362 @@
+package skiplist
+
+import (
+	"goalds/utils/comparator"
+	"goalds/utils/visitor"
+	"math/rand"
+	"sync/atomic"
+)
+
+// markedRef is an immutable (successor, deleted mark) pair. Links are swapped
+// with CAS on pointers to fresh markedRefs, which gives the atomic markable
+// reference the lock-free algorithm needs without pointer tagging.
+type markedRef[K, V any] struct {
+	node   *concurrentNode[K, V]
+	marked bool
+}
+
+type concurrentNode[K, V any] struct {
+	key K
+	// val is nil once the node has been logically removed.
+	val  atomic.Pointer[V]
+	next []atomic.Pointer[markedRef[K, V]]
+}
+
+func newConcurrentNode[K, V any](key K, val *V, level int) *concurrentNode[K, V] {
+	n := &concurrentNode[K, V]{
+		key:  key,
+		next: make([]atomic.Pointer[markedRef[K, V]], level),
+	}
+	n.val.Store(val)
+	return n
+}
+
+func (n *concurrentNode[K, V]) loadNext(level int) (*concurrentNode[K, V], bool) {
+	ref := n.next[level].Load()
+	if ref == nil {
+		return nil, false
+	}
+	return ref.node, ref.marked
+}
+
+func (n *concurrentNode[K, V]) casNext(level int, expected, node *concurrentNode[K, V], expectedMark, mark bool) bool {
+	ref := n.next[level].Load()
+	if ref == nil {
+		if expected != nil || expectedMark {
+			return false
+		}
+	} else if ref.node != expected || ref.marked != expectedMark {
+		return false
+	}
+	return n.next[level].CompareAndSwap(ref, &markedRef[K, V]{node: node, marked: mark})
+}
+
+// mark flags every link of a logically removed node, top level first.
+// It is idempotent, so any goroutine may help a pending removal.
+func (n *concurrentNode[K, V]) mark() {
+	for i := len(n.next) - 1; i >= 0; i-- {
+		for {
+			succ, marked := n.loadNext(i)
+			if marked || n.casNext(i, succ, succ, false, true) {
+				break
+			}
+		}
+	}
+}
+
+// defaultConcurrentMaxLevel is fixed because the head of a ConcurrentSkipList cannot
+// grow without a lock; 32 levels keep searches logarithmic up to billions of elements.
+var defaultConcurrentMaxLevel = 32
+
+// ConcurrentSkipList is a lock-free skiplist in the style of Java's ConcurrentSkipListMap.
+// Get, Insert and Remove are linearizable; Traversal, Keys and iterators are weakly
+// consistent: they never fail, but may or may not reflect concurrent modifications.
+type ConcurrentSkipList[K, V any] struct {
+	maxLevel    int
+	probability float64
+	head        *concurrentNode[K, V]
+	cmp         comparator.Comparator[K]
+	len         atomic.Int64
+}
+
+func NewConcurrent[K, V any](cmp comparator.Comparator[K], opts ...Option) *ConcurrentSkipList[K, V] {
+	option := Options{
+		maxLevel:    defaultConcurrentMaxLevel,
+		probability: defaultProbability,
+	}
+	for _, opt := range opts {
+		opt(&option)
+	}
+	if option.probability <= 0 || option.probability >= 1 {
+		panic("skiplist: probability must be in (0, 1)")
+	}
+	if option.maxLevel <= 0 {
+		option.maxLevel = defaultConcurrentMaxLevel
+	}
+
+	return &ConcurrentSkipList[K, V]{
+		maxLevel:    option.maxLevel,
+		probability: option.probability,
+		head:        newConcurrentNode[K, V](*new(K), nil, option.maxLevel),
+		cmp:         cmp,
+	}
+}
+
+func (sl *ConcurrentSkipList[K, V]) Insert(key K, val V) {
+	preds := make([]*concurrentNode[K, V], sl.maxLevel)
+	succs := make([]*concurrentNode[K, V], sl.maxLevel)
+	for {
+		if sl.find(key, preds, succs) {
+			node := succs[0]
+			old := node.val.Load()
+			if old != nil && node.val.CompareAndSwap(old, &val) {
+				return
+			}
+			if old == nil {
+				// the node is being removed, help mark it so the next find unlinks it
+				node.mark()
+			}
+			continue
+		}
+
+		level := sl.randomLevel()
+		node := newConcurrentNode(key, &val, level)
+		for i := 0; i < level; i++ {
+			node.next[i].Store(&markedRef[K, V]{node: succs[i]})
+		}
+		if !preds[0].casNext(0, succs[0], node, false, false) {
+			continue
+		}
+		sl.len.Add(1)
+		sl.linkUpperLevels(node, preds, succs)
+		return
+	}
+}
+
+func (sl *ConcurrentSkipList[K, V]) Get(key K) (V, error) {
+	pred := sl.head
+	var curr *concurrentNode[K, V]
+	for i := sl.maxLevel - 1; i >= 0; i-- {
+		curr, _ = pred.loadNext(i)
+		for curr != nil {
+			succ, marked := curr.loadNext(i)
+			if marked {
+				curr = succ
+				continue
+			}
+			if sl.cmp(curr.key, key) >= 0 {
+				break
+			}
+			pred = curr
+			curr = succ
+		}
+	}
+	if curr != nil && sl.cmp(curr.key, key) == 0 {
+		if val := curr.val.Load(); val != nil {
+			return *val, nil
+		}
+	}
+	return *new(V), ErrNotFound
+}
+
+func (sl *ConcurrentSkipList[K, V]) Remove(key K) bool {
+	preds := make([]*concurrentNode[K, V], sl.maxLevel)
+	succs := make([]*concurrentNode[K, V], sl.maxLevel)
+	for {
+		if !sl.find(key, preds, succs) {
+			return false
+		}
+		node := succs[0]
+		val := node.val.Load()
+		if val == nil {
+			// another goroutine won the removal
+			return false
+		}
+		if !node.val.CompareAndSwap(val, nil) {
+			continue
+		}
+		node.mark()
+		sl.len.Add(-1)
+		sl.find(key, preds, succs)
+		return true
+	}
+}
+
+// Len returns the number of elements. It is exact only when no modification is in progress.
+func (sl *ConcurrentSkipList[K, V]) Len() int {
+	return int(sl.len.Load())
+}
+
+func (sl *ConcurrentSkipList[K, V]) Traversal(visitor visitor.KVVisitor[K, V]) {
+	for node := sl.firstNode(); node != nil; node = sl.nextNode(node) {
+		if val := node.val.Load(); val != nil {
+			if !visitor(node.key, *val) {
+				break
+			}
+		}
+	}
+}
+
+func (sl *ConcurrentSkipList[K, V]) Keys() []K {
+	keys := make([]K, 0, sl.Len())
+	sl.Traversal(func(key K, _ V) bool {
+		keys = append(keys, key)
+		return true
+	})
+	return keys
+}
+
+// find fills preds and succs with the neighbours of key on every level,
+// unlinking any marked node it meets on the way. It reports whether succs[0] holds key.
+func (sl *ConcurrentSkipList[K, V]) find(key K, preds, succs []*concurrentNode[K, V]) bool {
+retry:
+	for {
+		pred := sl.head
+		var curr *concurrentNode[K, V]
+		for i := sl.maxLevel - 1; i >= 0; i-- {
+			curr, _ = pred.loadNext(i)
+			for curr != nil {
+				succ, marked := curr.loadNext(i)
+				if marked {
+					if !pred.casNext(i, curr, succ, false, false) {
+						continue retry
+					}
+					curr = succ
+					continue
+				}
+				if sl.cmp(curr.key, key) >= 0 {
+					break
+				}
+				pred = curr
+				curr = succ
+			}
+			preds[i] = pred
+			succs[i] = curr
+		}
+		return curr != nil && sl.cmp(curr.key, key) == 0
+	}
+}
+
+// linkUpperLevels links node, already present at level 0, into levels 1 and above.
+// It gives up as soon as the node is being removed.
+func (sl *ConcurrentSkipList[K, V]) linkUpperLevels(node *concurrentNode[K, V], preds, succs []*concurrentNode[K, V]) {
+	for i := 1; i < len(node.next); i++ {
+		for {
+			succ, marked := node.loadNext(i)
+			if marked {
+				return
+			}
+			if succ != succs[i] && !node.casNext(i, succ, succs[i], false, false) {
+				continue
+			}
+			if preds[i].casNext(i, succs[i], node, false, false) {
+				break
+			}
+			if !sl.find(node.key, preds, succs) || succs[0] != node {
+				return
+			}
+		}
+	}
+}
+
+// firstNode returns the first live node at level 0.
+func (sl *ConcurrentSkipList[K, V]) firstNode() *concurrentNode[K, V] {
+	node, _ := sl.head.loadNext(0)
+	return sl.skipRemoved(node)
+}
+
+func (sl *ConcurrentSkipList[K, V]) nextNode(node *concurrentNode[K, V]) *concurrentNode[K, V] {
+	next, _ := node.loadNext(0)
+	return sl.skipRemoved(next)
+}
+
+func (sl *ConcurrentSkipList[K, V]) skipRemoved(node *concurrentNode[K, V]) *concurrentNode[K, V] {
+	for node != nil && node.val.Load() == nil {
+		node, _ = node.loadNext(0)
+	}
+	return node
+}
+
+// seekNode returns the first live node whose key is >= key.
+func (sl *ConcurrentSkipList[K, V]) seekNode(key K) *concurrentNode[K, V] {
+	pred := sl.head
+	var curr *concurrentNode[K, V]
+	for i := sl.maxLevel - 1; i >= 0; i-- {
+		curr, _ = pred.loadNext(i)
+		for curr != nil && sl.cmp(curr.key, key) < 0 {
+			pred = curr
+			curr, _ = curr.loadNext(i)
+		}
+	}
+	return sl.skipRemoved(curr)
+}
+
+// randomLevel uses the global math/rand source, which is safe for concurrent use,
+// so WithSeed and WithRandSource have no effect on a ConcurrentSkipList.
+func (sl *ConcurrentSkipList[K, V]) randomLevel() int {
+	level := 1
+	for level < sl.maxLevel && rand.Float64() < sl.probability {
+		level++
+	}
+	return level
+}
+
+// ConcurrentIterator is a weakly consistent forward cursor over a ConcurrentSkipList.
+// It caches the key and value of its current element, so they stay readable
+// even if the element is removed afterwards.
+type ConcurrentIterator[K, V any] struct {
+	sl   *ConcurrentSkipList[K, V]
+	node *concurrentNode[K, V]
+	key  K
+	val  V
+}
+
+func (sl *ConcurrentSkipList[K, V]) Iterator() *ConcurrentIterator[K, V] {
+	return &ConcurrentIterator[K, V]{sl: sl}
+}
+
+func (it *ConcurrentIterator[K, V]) Valid() bool {
+	return it.node != nil
+}
+
+func (it *ConcurrentIterator[K, V]) SeekToFirst() bool {
+	return it.moveTo(it.sl.firstNode())
+}
+
+// Seek moves the iterator to the first element whose key is >= key.
+func (it *ConcurrentIterator[K, V]) Seek(key K) bool {
+	return it.moveTo(it.sl.seekNode(key))
+}
+
+func (it *ConcurrentIterator[K, V]) Next() bool {
+	if it.node == nil {
+		return false
+	}
+	return it.moveTo(it.sl.nextNode(it.node))
+}
+
+func (it *ConcurrentIterator[K, V]) Key() K {
+	if it.node == nil {
+		panic("skiplist: invalid iterator")
+	}
+	return it.key
+}
+
+func (it *ConcurrentIterator[K, V]) Value() V {
+	if it.node == nil {
+		panic("skiplist: invalid iterator")
+	}
+	return it.val
+}
+
+func (it *ConcurrentIterator[K, V]) moveTo(node *concurrentNode[K, V]) bool {
+	for node != nil {
+		if val := node.val.Load(); val != nil {
+			it.node, it.key, it.val = node, node.key, *val
+			return true
+		}
+		node = it.sl.nextNode(node)
+	}
+	it.node, it.key, it.val = nil, *new(K), *new(V)
+	return false
+}
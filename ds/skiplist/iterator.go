@@ -0,0 +1,81 @@
+package skiplist
+
+// Iterator is a bidirectional cursor over a SkipList.
+// A new iterator is not positioned; call SeekToFirst, SeekToLast or Seek before reading from it.
+// Modifying the list while iterating is allowed, but the iterator may still visit removed elements.
+type Iterator[K, V any] struct {
+	sl      *SkipList[K, V]
+	element *Element[K, V]
+}
+
+func (sl *SkipList[K, V]) Iterator() *Iterator[K, V] {
+	return &Iterator[K, V]{sl: sl}
+}
+
+func (it *Iterator[K, V]) Valid() bool {
+	return it.element != nil
+}
+
+func (it *Iterator[K, V]) SeekToFirst() bool {
+	it.sl.locker.RLock()
+	defer it.sl.locker.RUnlock()
+
+	it.element = it.sl.head.next[0]
+	return it.element != nil
+}
+
+func (it *Iterator[K, V]) SeekToLast() bool {
+	it.sl.locker.RLock()
+	defer it.sl.locker.RUnlock()
+
+	it.element = it.sl.tail
+	return it.element != nil
+}
+
+// Seek moves the iterator to the first element whose key is >= key.
+func (it *Iterator[K, V]) Seek(key K) bool {
+	it.sl.locker.RLock()
+	defer it.sl.locker.RUnlock()
+
+	it.element = it.sl.findFirst(key, Inclusive)
+	return it.element != nil
+}
+
+func (it *Iterator[K, V]) Next() bool {
+	if it.element == nil {
+		return false
+	}
+	it.sl.locker.RLock()
+	defer it.sl.locker.RUnlock()
+
+	it.element = it.element.next[0]
+	return it.element != nil
+}
+
+func (it *Iterator[K, V]) Prev() bool {
+	if it.element == nil {
+		return false
+	}
+	it.sl.locker.RLock()
+	defer it.sl.locker.RUnlock()
+
+	it.element = it.element.prev
+	return it.element != nil
+}
+
+func (it *Iterator[K, V]) Key() K {
+	if it.element == nil {
+		panic("skiplist: invalid iterator")
+	}
+	return it.element.key
+}
+
+func (it *Iterator[K, V]) Value() V {
+	if it.element == nil {
+		panic("skiplist: invalid iterator")
+	}
+	it.sl.locker.RLock()
+	defer it.sl.locker.RUnlock()
+
+	return it.element.val
+}
@@ -0,0 +1,255 @@
+package skiplist
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"goalds/utils/serialize"
+	"hash/crc32"
+	"io"
+	"math"
+	"math/bits"
+)
+
+const (
+	serializationMagic   = "GSKL"
+	serializationVersion = uint16(1)
+)
+
+var (
+	ErrNoCodec          = errors.New("skiplist: no codec, create the list with WithCodec")
+	ErrInvalidFormat    = errors.New("skiplist: invalid format")
+	ErrChecksumMismatch = errors.New("skiplist: checksum mismatch")
+	ErrUnsorted         = errors.New("skiplist: keys are not sorted")
+)
+
+// Codec encodes and decodes keys or values for WriteTo and ReadFrom.
+type Codec[T any] interface {
+	Encode(w io.Writer, v T) error
+	Decode(r io.Reader) (T, error)
+}
+
+// BinaryCodec encodes fixed-size values such as integers, floats and
+// structs of them with encoding/binary in little endian.
+type BinaryCodec[T any] struct{}
+
+func (BinaryCodec[T]) Encode(w io.Writer, v T) error {
+	return binary.Write(w, binary.LittleEndian, v)
+}
+
+func (BinaryCodec[T]) Decode(r io.Reader) (T, error) {
+	var v T
+	err := binary.Read(r, binary.LittleEndian, &v)
+	return v, err
+}
+
+// StringCodec encodes a string as a uint32 length followed by its bytes.
+type StringCodec struct{}
+
+func (StringCodec) Encode(w io.Writer, v string) error {
+	if err := binary.Write(w, binary.LittleEndian, uint32(len(v))); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, v)
+	return err
+}
+
+func (StringCodec) Decode(r io.Reader) (string, error) {
+	b, err := BytesCodec{}.Decode(r)
+	return string(b), err
+}
+
+// BytesCodec encodes a byte slice as a uint32 length followed by its bytes.
+type BytesCodec struct{}
+
+func (BytesCodec) Encode(w io.Writer, v []byte) error {
+	if err := binary.Write(w, binary.LittleEndian, uint32(len(v))); err != nil {
+		return err
+	}
+	_, err := w.Write(v)
+	return err
+}
+
+func (BytesCodec) Decode(r io.Reader) ([]byte, error) {
+	var n uint32
+	if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+		return nil, err
+	}
+	return serialize.ReadN(r, int64(n))
+}
+
+// WithCodec sets the codecs WriteTo and ReadFrom use for keys and values.
+// The codec types must match the key and value types of the list.
+func WithCodec[K, V any](keyCodec Codec[K], valCodec Codec[V]) Option {
+	return func(option *Options) {
+		option.keyCodec = keyCodec
+		option.valCodec = valCodec
+	}
+}
+
+// WriteTo writes the list to w as a versioned header, the elements in order
+// and a CRC32 checksum of everything before it.
+func (sl *SkipList[K, V]) WriteTo(w io.Writer) (int64, error) {
+	if sl.keyCodec == nil || sl.valCodec == nil {
+		return 0, ErrNoCodec
+	}
+	sl.locker.RLock()
+	defer sl.locker.RUnlock()
+
+	cw := &serialize.CountingWriter{W: w}
+	buf := bufio.NewWriter(cw)
+	crc := crc32.NewIEEE()
+	mw := io.MultiWriter(buf, crc)
+
+	if _, err := io.WriteString(mw, serializationMagic); err != nil {
+		return cw.N, err
+	}
+	if err := binary.Write(mw, binary.LittleEndian, serializationVersion); err != nil {
+		return cw.N, err
+	}
+	if err := binary.Write(mw, binary.LittleEndian, uint64(sl.len)); err != nil {
+		return cw.N, err
+	}
+	for e := sl.head.next[0]; e != nil; e = e.next[0] {
+		if err := sl.keyCodec.Encode(mw, e.key); err != nil {
+			return cw.N, err
+		}
+		if err := sl.valCodec.Encode(mw, e.val); err != nil {
+			return cw.N, err
+		}
+	}
+	if err := binary.Write(buf, binary.LittleEndian, crc.Sum32()); err != nil {
+		return cw.N, err
+	}
+	err := buf.Flush()
+	return cw.N, err
+}
+
+// ReadFrom replaces the content of the list with the data written by WriteTo.
+// The list is left untouched if the data is corrupted.
+func (sl *SkipList[K, V]) ReadFrom(r io.Reader) (int64, error) {
+	if sl.keyCodec == nil || sl.valCodec == nil {
+		return 0, ErrNoCodec
+	}
+	cr := &serialize.CountingReader{R: r}
+	crc := crc32.NewIEEE()
+	tr := io.TeeReader(cr, crc)
+
+	magic := make([]byte, len(serializationMagic))
+	if _, err := io.ReadFull(tr, magic); err != nil {
+		return cr.N, err
+	}
+	if string(magic) != serializationMagic {
+		return cr.N, ErrInvalidFormat
+	}
+	var version uint16
+	if err := binary.Read(tr, binary.LittleEndian, &version); err != nil {
+		return cr.N, err
+	}
+	if version != serializationVersion {
+		return cr.N, fmt.Errorf("skiplist: unsupported format version %d", version)
+	}
+	var count uint64
+	if err := binary.Read(tr, binary.LittleEndian, &count); err != nil {
+		return cr.N, err
+	}
+
+	keys := make([]K, 0)
+	vals := make([]V, 0)
+	for i := uint64(0); i < count; i++ {
+		key, err := sl.keyCodec.Decode(tr)
+		if err != nil {
+			return cr.N, err
+		}
+		val, err := sl.valCodec.Decode(tr)
+		if err != nil {
+			return cr.N, err
+		}
+		keys = append(keys, key)
+		vals = append(vals, val)
+	}
+	sum := crc.Sum32()
+	var expected uint32
+	if err := binary.Read(cr, binary.LittleEndian, &expected); err != nil {
+		return cr.N, err
+	}
+	if sum != expected {
+		return cr.N, ErrChecksumMismatch
+	}
+	return cr.N, sl.BulkLoad(keys, vals)
+}
+
+// BulkLoad replaces the content of the list with keys and vals, which must already be sorted.
+// Instead of drawing random levels it builds evenly spaced towers in O(n): with p = 1/2
+// the i-th element (counting from 1) gets one level per trailing zero bit of i, plus one.
+func (sl *SkipList[K, V]) BulkLoad(keys []K, vals []V) error {
+	if len(keys) != len(vals) {
+		return errors.New("skiplist: keys and vals have different lengths")
+	}
+	for i := 1; i < len(keys); i++ {
+		cmpRet := sl.cmp(keys[i-1], keys[i])
+		if cmpRet > 0 || (cmpRet == 0 && !sl.duplicates) {
+			return ErrUnsorted
+		}
+	}
+
+	sl.locker.Lock()
+	defer sl.locker.Unlock()
+
+	sl.growTo(len(keys))
+	lasts := sl.prevNodesCache
+	ranks := sl.prevRanksCache
+	for i := 0; i < sl.maxLevel; i++ {
+		sl.head.next[i] = nil
+		lasts[i] = &sl.head
+		ranks[i] = 0
+	}
+	sl.tail = nil
+	for i := range keys {
+		rank := i + 1
+		level := sl.bulkLevel(rank)
+		e := &Element[K, V]{
+			key:  keys[i],
+			val:  vals[i],
+			prev: sl.tail,
+			Node: Node[K, V]{
+				next: make([]*Element[K, V], level),
+				span: make([]int, level),
+			},
+		}
+		for j := 0; j < level; j++ {
+			lasts[j].next[j] = e
+			lasts[j].span[j] = rank - ranks[j]
+			lasts[j] = &e.Node
+			ranks[j] = rank
+		}
+		sl.tail = e
+	}
+	for i := 0; i < sl.maxLevel; i++ {
+		lasts[i].span[i] = len(keys) - ranks[i]
+	}
+	sl.len = len(keys)
+	return nil
+}
+
+// bulkLevel returns the level of the element at the 1-based rank in a balanced list:
+// one plus the number of trailing zero digits of rank written in base 1/p.
+func (sl *SkipList[K, V]) bulkLevel(rank int) int {
+	base := int(math.Round(1 / sl.probability))
+	if base < 2 {
+		base = 2
+	}
+	level := 1
+	if base == 2 {
+		level = bits.TrailingZeros(uint(rank)) + 1
+	} else {
+		for ; rank%base == 0; rank /= base {
+			level++
+		}
+	}
+	if level > sl.maxLevel {
+		level = sl.maxLevel
+	}
+	return level
+}
@@ -5,72 +5,167 @@ import (
 	"goalds/utils/comparator"
 	"goalds/utils/locker"
 	"goalds/utils/visitor"
+	"math"
 	"math/rand"
 	"sync"
 	"time"
 )
 
+// Promotion probabilities for WithProbability.
+const (
+	ProbabilityHalf    = 0.5
+	ProbabilityQuarter = 0.25
+	ProbabilityInvE    = 1 / math.E
+)
+
 var (
-	defaultLocker   locker.FakeLocker
-	defaultMaxLevel = 16
+	defaultLocker      locker.FakeLocker
+	defaultMaxLevel    = 16
+	defaultProbability = ProbabilityHalf
+	// adaptiveLevelLimit bounds how far an adaptive SkipList may grow.
+	adaptiveLevelLimit = 64
+
+	ErrNotFound = errors.New("not found")
 )
 
 type Options struct {
-	maxLevel int
-	locker   locker.Locker
+	maxLevel    int
+	locker      locker.Locker
+	duplicates  bool
+	keyCodec    any
+	valCodec    any
+	probability float64
+	source      rand.Source
 }
 
 type Option func(option *Options)
 
+// WithMaxLevel fixes the number of levels. Without it a SkipList starts with
+// 16 levels and adds one whenever its length reaches (1/p)^levels.
 func WithMaxLevel(maxLevel int) Option {
 	return func(option *Options) {
 		option.maxLevel = maxLevel
 	}
 }
 
+// WithProbability sets the probability p that an element is promoted to the next level,
+// see ProbabilityHalf, ProbabilityQuarter and ProbabilityInvE. Smaller p uses less memory
+// per element at the cost of longer searches on each level.
+func WithProbability(p float64) Option {
+	return func(option *Options) {
+		option.probability = p
+	}
+}
+
+// WithSeed makes level generation reproducible.
+func WithSeed(seed int64) Option {
+	return func(option *Options) {
+		option.source = rand.NewSource(seed)
+	}
+}
+
+// WithRandSource draws levels from src. SkipList only uses src while holding its
+// write lock, but src must not be shared with other users unless it is goroutine safe.
+func WithRandSource(src rand.Source) Option {
+	return func(option *Options) {
+		option.source = src
+	}
+}
+
 func WithGoroutineSafe() Option {
 	return func(option *Options) {
 		option.locker = &sync.RWMutex{}
 	}
 }
 
+// WithDuplicates turns the SkipList into a multimap: Insert keeps equal keys
+// in insertion order instead of overwriting. It has no effect on ConcurrentSkipList.
+func WithDuplicates() Option {
+	return func(option *Options) {
+		option.duplicates = true
+	}
+}
+
+// Bound describes whether an endpoint of a range is part of the range.
+type Bound int
+
+const (
+	Inclusive Bound = iota
+	Exclusive
+)
+
 type Element[K, V any] struct {
 	Node[K, V]
-	key K
-	val V
+	key  K
+	val  V
+	prev *Element[K, V]
 }
 
+// Node holds the forward pointers of an element. span[i] is the number of
+// level 0 steps from this node to next[i], or to the end of the list if next[i] is nil.
 type Node[K, V any] struct {
 	next []*Element[K, V]
+	span []int
 }
 
 type SkipList[K, V any] struct {
 	locker         locker.Locker
 	maxLevel       int
+	adaptive       bool
+	growAt         int
+	probability    float64
+	duplicates     bool
 	head           Node[K, V]
+	tail           *Element[K, V]
 	cmp            comparator.Comparator[K]
 	len            int
 	prevNodesCache []*Node[K, V]
+	prevRanksCache []int
 	rander         *rand.Rand
+	keyCodec       Codec[K]
+	valCodec       Codec[V]
 }
 
 func New[K, V any](cmp comparator.Comparator[K], opts ...Option) *SkipList[K, V] {
 	option := Options{
-		maxLevel: defaultMaxLevel,
-		locker:   defaultLocker,
+		locker:      defaultLocker,
+		probability: defaultProbability,
 	}
 	for _, opt := range opts {
 		opt(&option)
 	}
+	if option.probability <= 0 || option.probability >= 1 {
+		panic("skiplist: probability must be in (0, 1)")
+	}
+	adaptive := option.maxLevel <= 0
+	if adaptive {
+		option.maxLevel = defaultMaxLevel
+	}
+	if option.source == nil {
+		option.source = rand.NewSource(time.Now().UnixNano())
+	}
 
 	sl := &SkipList[K, V]{
 		locker:         option.locker,
 		maxLevel:       option.maxLevel,
-		head:           Node[K, V]{next: make([]*Element[K, V], option.maxLevel)},
+		adaptive:       adaptive,
+		probability:    option.probability,
+		duplicates:     option.duplicates,
+		head:           Node[K, V]{next: make([]*Element[K, V], option.maxLevel), span: make([]int, option.maxLevel)},
 		cmp:            cmp,
 		len:            0,
 		prevNodesCache: make([]*Node[K, V], option.maxLevel),
-		rander:         rand.New(rand.NewSource(time.Now().Unix())),
+		prevRanksCache: make([]int, option.maxLevel),
+		rander:         rand.New(option.source),
+	}
+	sl.growAt = sl.capacityOf(sl.maxLevel)
+	if option.keyCodec != nil {
+		keyCodec, ok1 := option.keyCodec.(Codec[K])
+		valCodec, ok2 := option.valCodec.(Codec[V])
+		if !ok1 || !ok2 {
+			panic("skiplist: codec types do not match the key and value types")
+		}
+		sl.keyCodec, sl.valCodec = keyCodec, valCodec
 	}
 	return sl
 }
@@ -79,10 +174,18 @@ func (sl *SkipList[K, V]) Insert(key K, val V) {
 	sl.locker.Lock()
 	defer sl.locker.Unlock()
 
-	prevs := sl.findPrevNodes(key)
-	for prevs[0].next[0] != nil && sl.cmp(prevs[0].next[0].key, key) == 0 {
-		prevs[0].next[0].val = val
-		return
+	sl.growTo(sl.len + 1)
+	var prevs []*Node[K, V]
+	var ranks []int
+	if sl.duplicates {
+		// equal keys go after the existing ones to keep insertion order
+		prevs, ranks = sl.findPrevNodes(key, Exclusive)
+	} else {
+		prevs, ranks = sl.findPrevNodes(key, Inclusive)
+		if prevs[0].next[0] != nil && sl.cmp(prevs[0].next[0].key, key) == 0 {
+			prevs[0].next[0].val = val
+			return
+		}
 	}
 
 	level := sl.randomLevel()
@@ -91,11 +194,24 @@ func (sl *SkipList[K, V]) Insert(key K, val V) {
 		val: val,
 		Node: Node[K, V]{
 			next: make([]*Element[K, V], level),
+			span: make([]int, level),
 		},
 	}
 	for i := range e.Node.next {
 		e.Node.next[i] = prevs[i].next[i]
 		prevs[i].next[i] = e
+		e.span[i] = prevs[i].span[i] - (ranks[0] - ranks[i])
+		prevs[i].span[i] = ranks[0] - ranks[i] + 1
+	}
+	for i := level; i < sl.maxLevel; i++ {
+		prevs[i].span[i]++
+	}
+	if next := e.next[0]; next != nil {
+		e.prev = next.prev
+		next.prev = e
+	} else {
+		e.prev = sl.tail
+		sl.tail = e
 	}
 	sl.len++
 }
@@ -104,40 +220,124 @@ func (sl *SkipList[K, V]) Get(key K) (V, error) {
 	sl.locker.RLock()
 	defer sl.locker.RUnlock()
 
-	pre := &sl.head
-	for i := sl.maxLevel - 1; i >= 0; i-- {
-		cur := pre.next[i]
-		for ; cur != nil; cur = cur.next[i] {
-			cmpRet := sl.cmp(cur.key, key)
-			if cmpRet == 0 {
-				return cur.val, nil
-			}
-			if cmpRet > 0 {
-				break
-			}
-			pre = &cur.Node
-		}
+	e := sl.findFirst(key, Inclusive)
+	if e == nil || sl.cmp(e.key, key) != 0 {
+		return *new(V), ErrNotFound
 	}
-	return *new(V), errors.New("not found")
+	return e.val, nil
+}
+
+// GetAll returns the values of every element with key, in insertion order when WithDuplicates is used.
+func (sl *SkipList[K, V]) GetAll(key K) []V {
+	sl.locker.RLock()
+	defer sl.locker.RUnlock()
+
+	vals := make([]V, 0)
+	for e := sl.findFirst(key, Inclusive); e != nil && sl.cmp(e.key, key) == 0; e = e.next[0] {
+		vals = append(vals, e.val)
+	}
+	return vals
+}
+
+// Count returns the number of elements with key.
+func (sl *SkipList[K, V]) Count(key K) int {
+	sl.locker.RLock()
+	defer sl.locker.RUnlock()
+
+	_, lo := sl.search(key, Inclusive)
+	_, hi := sl.search(key, Exclusive)
+	return hi - lo
 }
 
 func (sl *SkipList[K, V]) Remove(key K) bool {
 	sl.locker.Lock()
 	defer sl.locker.Unlock()
 
-	prevs := sl.findPrevNodes(key)
-	element := prevs[0].next[0]
+	return sl.removeFirstMatch(key, func(*Element[K, V]) bool { return true }) != nil
+}
+
+// RemoveOne removes the first element with key whose value satisfies pred.
+func (sl *SkipList[K, V]) RemoveOne(key K, pred func(val V) bool) bool {
+	sl.locker.Lock()
+	defer sl.locker.Unlock()
+
+	return sl.removeFirstMatch(key, func(e *Element[K, V]) bool { return pred(e.val) }) != nil
+}
+
+// Floor returns the element with the greatest key <= key.
+func (sl *SkipList[K, V]) Floor(key K) (K, V, error) {
+	sl.locker.RLock()
+	defer sl.locker.RUnlock()
+
+	return sl.kv(sl.prevOf(sl.findFirst(key, Exclusive)))
+}
+
+// Ceiling returns the element with the smallest key >= key.
+func (sl *SkipList[K, V]) Ceiling(key K) (K, V, error) {
+	sl.locker.RLock()
+	defer sl.locker.RUnlock()
+
+	return sl.kv(sl.findFirst(key, Inclusive))
+}
+
+// Lower returns the element with the greatest key < key.
+func (sl *SkipList[K, V]) Lower(key K) (K, V, error) {
+	sl.locker.RLock()
+	defer sl.locker.RUnlock()
+
+	return sl.kv(sl.prevOf(sl.findFirst(key, Inclusive)))
+}
+
+// Higher returns the element with the smallest key > key.
+func (sl *SkipList[K, V]) Higher(key K) (K, V, error) {
+	sl.locker.RLock()
+	defer sl.locker.RUnlock()
+
+	return sl.kv(sl.findFirst(key, Exclusive))
+}
+
+func (sl *SkipList[K, V]) First() (K, V, error) {
+	sl.locker.RLock()
+	defer sl.locker.RUnlock()
+
+	return sl.kv(sl.head.next[0])
+}
+
+func (sl *SkipList[K, V]) Last() (K, V, error) {
+	sl.locker.RLock()
+	defer sl.locker.RUnlock()
+
+	return sl.kv(sl.tail)
+}
+
+// PopFirst removes and returns the element with the smallest key.
+func (sl *SkipList[K, V]) PopFirst() (K, V, error) {
+	sl.locker.Lock()
+	defer sl.locker.Unlock()
+
+	element := sl.head.next[0]
 	if element == nil {
-		return false
+		return sl.kv(nil)
 	}
-	if sl.cmp(element.key, key) != 0 {
-		return false
+	prevs := sl.prevNodesCache
+	for i := range prevs {
+		prevs[i] = &sl.head
 	}
-	for i, v := range element.next {
-		prevs[i].next[i] = v
+	sl.removeElement(prevs, element)
+	return sl.kv(element)
+}
+
+// PopLast removes and returns the element with the greatest key.
+func (sl *SkipList[K, V]) PopLast() (K, V, error) {
+	sl.locker.Lock()
+	defer sl.locker.Unlock()
+
+	element := sl.tail
+	if element == nil {
+		return sl.kv(nil)
 	}
-	sl.len--
-	return true
+	sl.removeFirstMatch(element.key, func(e *Element[K, V]) bool { return e == element })
+	return sl.kv(element)
 }
 
 func (sl *SkipList[K, V]) Len() int {
@@ -158,6 +358,77 @@ func (sl *SkipList[K, V]) Traversal(visitor visitor.KVVisitor[K, V]) {
 	}
 }
 
+// Range calls visitor for every key between lo and hi in ascending order.
+// loBound and hiBound decide whether lo and hi themselves are included.
+func (sl *SkipList[K, V]) Range(lo K, loBound Bound, hi K, hiBound Bound, visitor visitor.KVVisitor[K, V]) {
+	sl.locker.RLock()
+	defer sl.locker.RUnlock()
+
+	for e := sl.findFirst(lo, loBound); e != nil; e = e.next[0] {
+		cmpRet := sl.cmp(e.key, hi)
+		if cmpRet > 0 || (cmpRet == 0 && hiBound == Exclusive) {
+			break
+		}
+		if !visitor(e.key, e.val) {
+			break
+		}
+	}
+}
+
+// Rank returns the 0-based position of key in ascending order.
+func (sl *SkipList[K, V]) Rank(key K) (int, error) {
+	sl.locker.RLock()
+	defer sl.locker.RUnlock()
+
+	e, rank := sl.search(key, Inclusive)
+	if e == nil || sl.cmp(e.key, key) != 0 {
+		return -1, ErrNotFound
+	}
+	return rank, nil
+}
+
+// At returns the element at the 0-based position rank.
+// A negative rank counts from the end, -1 being the last element.
+func (sl *SkipList[K, V]) At(rank int) (K, V, error) {
+	sl.locker.RLock()
+	defer sl.locker.RUnlock()
+
+	if rank < 0 {
+		rank += sl.len
+	}
+	return sl.kv(sl.elementAt(rank))
+}
+
+// RangeByRank calls visitor for the elements whose positions are in [start, stop].
+// Like Redis ZRANGE, both ends are inclusive and negative positions count from the end.
+func (sl *SkipList[K, V]) RangeByRank(start, stop int, visitor visitor.KVVisitor[K, V]) {
+	sl.locker.RLock()
+	defer sl.locker.RUnlock()
+
+	if start < 0 {
+		start += sl.len
+	}
+	if stop < 0 {
+		stop += sl.len
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= sl.len {
+		stop = sl.len - 1
+	}
+	if start > stop {
+		return
+	}
+	e := sl.elementAt(start)
+	for n := stop - start + 1; n > 0; n-- {
+		if !visitor(e.key, e.val) {
+			break
+		}
+		e = e.next[0]
+	}
+}
+
 func (sl *SkipList[K, V]) Keys() []K {
 	sl.locker.RLock()
 	defer sl.locker.RUnlock()
@@ -168,32 +439,150 @@ func (sl *SkipList[K, V]) Keys() []K {
 	return keys
 }
 
-func (sl *SkipList[K, V]) findPrevNodes(key K) []*Node[K, V] {
+// findPrevNodes returns, for every level, the last node whose key is < key (<= key if bound is Exclusive)
+// together with the rank of that node (0 for the head).
+func (sl *SkipList[K, V]) findPrevNodes(key K, bound Bound) ([]*Node[K, V], []int) {
 	prevs := sl.prevNodesCache
+	ranks := sl.prevRanksCache
 	prev := &sl.head
+	rank := 0
 	for i := sl.maxLevel - 1; i >= 0; i-- {
 		if sl.head.next[i] != nil {
 			for next := prev.next[i]; next != nil; next = next.next[i] {
-				if sl.cmp(next.key, key) >= 0 {
+				cmpRet := sl.cmp(next.key, key)
+				if cmpRet > 0 || (cmpRet == 0 && bound == Inclusive) {
 					break
 				}
+				rank += prev.span[i]
 				prev = &next.Node
 			}
 		}
 		prevs[i] = prev
+		ranks[i] = rank
 	}
-	return prevs
+	return prevs, ranks
 }
 
-func (sl *SkipList[K, V]) randomLevel() int {
-	total := uint64(1)<<uint64(sl.maxLevel) - 1 // 2^n-1
-	k := sl.rander.Uint64() % total
-	levelN := uint64(1) << (uint64(sl.maxLevel) - 1)
+// elementAt returns the element at the 0-based position rank, or nil if rank is out of range.
+func (sl *SkipList[K, V]) elementAt(rank int) *Element[K, V] {
+	if rank < 0 || rank >= sl.len {
+		return nil
+	}
+	target := rank + 1
+	prev := &sl.head
+	traversed := 0
+	for i := sl.maxLevel - 1; i >= 0; i-- {
+		for prev.next[i] != nil && traversed+prev.span[i] <= target {
+			traversed += prev.span[i]
+			if traversed == target {
+				return prev.next[i]
+			}
+			prev = &prev.next[i].Node
+		}
+	}
+	return nil
+}
+
+// removeFirstMatch removes the first element with key for which match returns true.
+func (sl *SkipList[K, V]) removeFirstMatch(key K, match func(e *Element[K, V]) bool) *Element[K, V] {
+	prevs, _ := sl.findPrevNodes(key, Inclusive)
+	for e := prevs[0].next[0]; e != nil && sl.cmp(e.key, key) == 0; e = e.next[0] {
+		if match(e) {
+			sl.removeElement(prevs, e)
+			return e
+		}
+		for i := range e.next {
+			prevs[i] = &e.Node
+		}
+	}
+	return nil
+}
+
+func (sl *SkipList[K, V]) removeElement(prevs []*Node[K, V], element *Element[K, V]) {
+	for i := 0; i < sl.maxLevel; i++ {
+		if prevs[i].next[i] == element {
+			prevs[i].span[i] += element.span[i] - 1
+			prevs[i].next[i] = element.next[i]
+		} else {
+			prevs[i].span[i]--
+		}
+	}
+	if next := element.next[0]; next != nil {
+		next.prev = element.prev
+	} else {
+		sl.tail = element.prev
+	}
+	sl.len--
+}
+
+// prevOf returns the element before e, where a nil e stands for the position past the tail.
+func (sl *SkipList[K, V]) prevOf(e *Element[K, V]) *Element[K, V] {
+	if e == nil {
+		return sl.tail
+	}
+	return e.prev
+}
 
+func (sl *SkipList[K, V]) kv(e *Element[K, V]) (K, V, error) {
+	if e == nil {
+		return *new(K), *new(V), ErrNotFound
+	}
+	return e.key, e.val, nil
+}
+
+// findFirst returns the first element whose key is >= key, or > key if bound is Exclusive.
+func (sl *SkipList[K, V]) findFirst(key K, bound Bound) *Element[K, V] {
+	e, _ := sl.search(key, bound)
+	return e
+}
+
+// search is findFirst that also returns the 0-based rank of the element, or Len() if there is none.
+// Unlike findPrevNodes it does not touch prevNodesCache, so it is safe under a read lock.
+func (sl *SkipList[K, V]) search(key K, bound Bound) (*Element[K, V], int) {
+	prev := &sl.head
+	rank := 0
+	for i := sl.maxLevel - 1; i >= 0; i-- {
+		for next := prev.next[i]; next != nil; next = next.next[i] {
+			cmpRet := sl.cmp(next.key, key)
+			if cmpRet > 0 || (cmpRet == 0 && bound == Inclusive) {
+				break
+			}
+			rank += prev.span[i]
+			prev = &next.Node
+		}
+	}
+	return prev.next[0], rank
+}
+
+func (sl *SkipList[K, V]) randomLevel() int {
 	level := 1
-	for total -= levelN; total > k; level++ {
-		levelN >>= 1
-		total -= levelN
+	for level < sl.maxLevel && sl.rander.Float64() < sl.probability {
+		level++
 	}
 	return level
 }
+
+// capacityOf returns the length up to which level levels keep searches logarithmic, (1/p)^level.
+func (sl *SkipList[K, V]) capacityOf(level int) int {
+	capacity := math.Pow(1/sl.probability, float64(level))
+	if capacity >= math.MaxInt {
+		return math.MaxInt
+	}
+	return int(capacity)
+}
+
+// growTo adds levels to an adaptive list until it can hold n elements.
+func (sl *SkipList[K, V]) growTo(n int) {
+	for sl.adaptive && n > sl.growAt && sl.maxLevel < adaptiveLevelLimit {
+		sl.addLevel()
+	}
+}
+
+func (sl *SkipList[K, V]) addLevel() {
+	sl.maxLevel++
+	sl.head.next = append(sl.head.next, nil)
+	sl.head.span = append(sl.head.span, sl.len)
+	sl.prevNodesCache = append(sl.prevNodesCache, nil)
+	sl.prevRanksCache = append(sl.prevRanksCache, 0)
+	sl.growAt = sl.capacityOf(sl.maxLevel)
+}
@@ -1,8 +1,14 @@
 package skiplist
 
 import (
+	"bytes"
+	"errors"
 	"goalds/utils/comparator"
+	"io"
 	"math/rand"
+	"runtime"
+	"strconv"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -84,3 +90,753 @@ func TestTraversal(t *testing.T) {
 	})
 	assert.Equal(t, 5, len(keys))
 }
+
+func TestIterator(t *testing.T) {
+	sl := New[int, int](comparator.OrderedTypeCmp[int], WithGoroutineSafe())
+	it := sl.Iterator()
+	assert.False(t, it.Valid())
+	assert.False(t, it.SeekToFirst())
+	assert.False(t, it.SeekToLast())
+	assert.Panics(t, func() { it.Key() })
+
+	for i := 0; i < 100; i++ {
+		sl.Insert(i*2, i)
+	}
+
+	assert.True(t, it.Seek(51))
+	assert.Equal(t, 52, it.Key())
+	assert.Equal(t, 26, it.Value())
+	assert.True(t, it.Seek(52))
+	assert.Equal(t, 52, it.Key())
+	assert.False(t, it.Seek(199))
+
+	n := 0
+	for ok := it.SeekToFirst(); ok; ok = it.Next() {
+		assert.Equal(t, n*2, it.Key())
+		n++
+	}
+	assert.Equal(t, 100, n)
+
+	for ok := it.SeekToLast(); ok; ok = it.Prev() {
+		n--
+		assert.Equal(t, n*2, it.Key())
+	}
+	assert.Equal(t, 0, n)
+
+	sl.Remove(198)
+	sl.Remove(0)
+	assert.True(t, it.SeekToLast())
+	assert.Equal(t, 196, it.Key())
+	assert.True(t, it.SeekToFirst())
+	assert.Equal(t, 2, it.Key())
+	assert.False(t, it.Prev())
+}
+
+func TestRange(t *testing.T) {
+	sl := New[int, int](comparator.OrderedTypeCmp[int])
+	for i := 0; i < 20; i++ {
+		sl.Insert(i, i*10)
+	}
+	collect := func(lo int, loBound Bound, hi int, hiBound Bound) []int {
+		keys := make([]int, 0)
+		sl.Range(lo, loBound, hi, hiBound, func(key, value int) bool {
+			assert.Equal(t, key*10, value)
+			keys = append(keys, key)
+			return true
+		})
+		return keys
+	}
+	assert.Equal(t, []int{5, 6, 7, 8}, collect(5, Inclusive, 8, Inclusive))
+	assert.Equal(t, []int{6, 7}, collect(5, Exclusive, 8, Exclusive))
+	assert.Equal(t, []int{5, 6, 7}, collect(5, Inclusive, 8, Exclusive))
+	assert.Equal(t, []int{18, 19}, collect(18, Inclusive, 100, Inclusive))
+	assert.Equal(t, []int{}, collect(8, Inclusive, 5, Inclusive))
+	assert.Equal(t, []int{}, collect(5, Exclusive, 5, Inclusive))
+
+	keys := make([]int, 0)
+	sl.Range(0, Inclusive, 19, Inclusive, func(key, value int) bool {
+		keys = append(keys, key)
+		return len(keys) < 3
+	})
+	assert.Equal(t, []int{0, 1, 2}, keys)
+}
+
+func TestNeighbors(t *testing.T) {
+	sl := New[int, string](comparator.OrderedTypeCmp[int], WithGoroutineSafe())
+	_, _, err := sl.First()
+	assert.Equal(t, ErrNotFound, err)
+	_, _, err = sl.Floor(10)
+	assert.Equal(t, ErrNotFound, err)
+	_, _, err = sl.PopFirst()
+	assert.Equal(t, ErrNotFound, err)
+	_, _, err = sl.PopLast()
+	assert.Equal(t, ErrNotFound, err)
+
+	for i := 10; i <= 50; i += 10 {
+		sl.Insert(i, strconv.Itoa(i))
+	}
+
+	check := func(f func(int) (int, string, error), key int, expect int) {
+		k, v, err := f(key)
+		if expect < 0 {
+			assert.Equal(t, ErrNotFound, err)
+			return
+		}
+		assert.Nil(t, err)
+		assert.Equal(t, expect, k)
+		assert.Equal(t, strconv.Itoa(expect), v)
+	}
+	check(sl.Floor, 30, 30)
+	check(sl.Floor, 35, 30)
+	check(sl.Floor, 5, -1)
+	check(sl.Floor, 100, 50)
+	check(sl.Ceiling, 30, 30)
+	check(sl.Ceiling, 35, 40)
+	check(sl.Ceiling, 55, -1)
+	check(sl.Lower, 30, 20)
+	check(sl.Lower, 10, -1)
+	check(sl.Lower, 100, 50)
+	check(sl.Higher, 30, 40)
+	check(sl.Higher, 50, -1)
+	check(sl.Higher, 0, 10)
+
+	k, _, _ := sl.First()
+	assert.Equal(t, 10, k)
+	k, _, _ = sl.Last()
+	assert.Equal(t, 50, k)
+
+	k, v, err := sl.PopFirst()
+	assert.Nil(t, err)
+	assert.Equal(t, 10, k)
+	assert.Equal(t, "10", v)
+	k, _, _ = sl.PopLast()
+	assert.Equal(t, 50, k)
+	assert.Equal(t, []int{20, 30, 40}, sl.Keys())
+	assert.Equal(t, 3, sl.Len())
+
+	k, _, _ = sl.First()
+	assert.Equal(t, 20, k)
+	k, _, _ = sl.Last()
+	assert.Equal(t, 40, k)
+}
+
+func TestPop(t *testing.T) {
+	sl := New[int, int](comparator.OrderedTypeCmp[int])
+	for i := 0; i < 1000; i++ {
+		sl.Insert(rand.Int()%500, i)
+	}
+	keys := sl.Keys()
+	for len(keys) > 0 {
+		k, _, err := sl.PopFirst()
+		assert.Nil(t, err)
+		assert.Equal(t, keys[0], k)
+		keys = keys[1:]
+		if len(keys) == 0 {
+			break
+		}
+		k, _, err = sl.PopLast()
+		assert.Nil(t, err)
+		assert.Equal(t, keys[len(keys)-1], k)
+		keys = keys[:len(keys)-1]
+		assert.Equal(t, keys, sl.Keys())
+	}
+	assert.Equal(t, 0, sl.Len())
+}
+
+func TestRank(t *testing.T) {
+	sl := New[int, int](comparator.OrderedTypeCmp[int], WithMaxLevel(10))
+	_, err := sl.Rank(1)
+	assert.Equal(t, ErrNotFound, err)
+	_, _, err = sl.At(0)
+	assert.Equal(t, ErrNotFound, err)
+
+	for i := 0; i < 2000; i++ {
+		key := rand.Int() % 1000
+		if i%3 == 0 {
+			sl.Remove(key)
+		} else {
+			sl.Insert(key, key*10)
+		}
+		if i%7 == 0 {
+			sl.PopFirst()
+		}
+		if i%11 == 0 {
+			sl.PopLast()
+		}
+	}
+	keys := sl.Keys()
+	for i, key := range keys {
+		rank, err := sl.Rank(key)
+		assert.Nil(t, err)
+		assert.Equal(t, i, rank)
+		k, v, err := sl.At(i)
+		assert.Nil(t, err)
+		assert.Equal(t, key, k)
+		assert.Equal(t, key*10, v)
+	}
+	k, _, _ := sl.At(-1)
+	assert.Equal(t, keys[len(keys)-1], k)
+	_, _, err = sl.At(len(keys))
+	assert.Equal(t, ErrNotFound, err)
+	_, _, err = sl.At(-len(keys) - 1)
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestRangeByRank(t *testing.T) {
+	sl := New[int, int](comparator.OrderedTypeCmp[int])
+	for i := 0; i < 10; i++ {
+		sl.Insert(i, i)
+	}
+	collect := func(start, stop int) []int {
+		keys := make([]int, 0)
+		sl.RangeByRank(start, stop, func(key, value int) bool {
+			keys = append(keys, key)
+			return true
+		})
+		return keys
+	}
+	assert.Equal(t, []int{2, 3, 4}, collect(2, 4))
+	assert.Equal(t, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, collect(0, -1))
+	assert.Equal(t, []int{7, 8, 9}, collect(-3, 100))
+	assert.Equal(t, []int{0, 1}, collect(-100, 1))
+	assert.Equal(t, []int{}, collect(5, 4))
+	assert.Equal(t, []int{}, collect(10, 20))
+}
+
+func TestConcurrentSkipList(t *testing.T) {
+	sl := NewConcurrent[int, int](comparator.OrderedTypeCmp[int])
+	_, err := sl.Get(1)
+	assert.Equal(t, ErrNotFound, err)
+	assert.False(t, sl.Remove(1))
+
+	m := make(map[int]int)
+	for i := 0; i < 1000; i++ {
+		key := rand.Int() % 500
+		if i%4 == 0 {
+			assert.Equal(t, m[key] != 0, sl.Remove(key))
+			delete(m, key)
+		} else {
+			sl.Insert(key, i+1)
+			m[key] = i + 1
+		}
+	}
+	assert.Equal(t, len(m), sl.Len())
+	for key, v := range m {
+		ret, err := sl.Get(key)
+		assert.Nil(t, err)
+		assert.Equal(t, v, ret)
+	}
+	keys := sl.Keys()
+	assert.Equal(t, len(m), len(keys))
+	for i := 1; i < len(keys); i++ {
+		assert.True(t, keys[i-1] < keys[i])
+	}
+
+	it := sl.Iterator()
+	assert.False(t, it.Valid())
+	assert.True(t, it.Seek(keys[len(keys)/2]))
+	assert.Equal(t, keys[len(keys)/2], it.Key())
+	assert.Equal(t, m[it.Key()], it.Value())
+	n := 0
+	for ok := it.SeekToFirst(); ok; ok = it.Next() {
+		assert.Equal(t, keys[n], it.Key())
+		n++
+	}
+	assert.Equal(t, len(keys), n)
+}
+
+func TestConcurrentSkipListStress(t *testing.T) {
+	sl := NewConcurrent[int, int](comparator.OrderedTypeCmp[int], WithMaxLevel(8))
+	const goroutines = 8
+	const keysPerGoroutine = 500
+
+	// every goroutine owns a disjoint key range, plus a shared range everybody fights over
+	done := make(chan map[int]int)
+	for g := 0; g < goroutines; g++ {
+		go func(g int) {
+			r := rand.New(rand.NewSource(int64(g)))
+			owned := make(map[int]int)
+			for i := 0; i < 4000; i++ {
+				key := g*keysPerGoroutine + r.Intn(keysPerGoroutine)
+				switch r.Intn(3) {
+				case 0:
+					sl.Remove(key)
+					delete(owned, key)
+				default:
+					sl.Insert(key, i)
+					owned[key] = i
+				}
+				shared := -1 - r.Intn(50)
+				if i%2 == 0 {
+					sl.Insert(shared, i)
+				} else {
+					sl.Remove(shared)
+				}
+				sl.Get(shared)
+				if i%100 == 0 {
+					sl.Traversal(func(key, value int) bool { return true })
+				}
+			}
+			done <- owned
+		}(g)
+	}
+
+	expected := make(map[int]int)
+	for g := 0; g < goroutines; g++ {
+		for k, v := range <-done {
+			expected[k] = v
+		}
+	}
+	for k, v := range expected {
+		ret, err := sl.Get(k)
+		assert.Nil(t, err)
+		assert.Equal(t, v, ret)
+	}
+	keys := sl.Keys()
+	for i := 1; i < len(keys); i++ {
+		assert.True(t, keys[i-1] < keys[i])
+	}
+	assert.Equal(t, len(keys), sl.Len())
+	nonNegative := 0
+	for _, k := range keys {
+		if k >= 0 {
+			nonNegative++
+		}
+	}
+	assert.Equal(t, len(expected), nonNegative)
+}
+
+func TestDuplicates(t *testing.T) {
+	sl := New[int, int](comparator.OrderedTypeCmp[int], WithDuplicates(), WithGoroutineSafe())
+	for i := 0; i < 10; i++ {
+		sl.Insert(i%3, i)
+	}
+	assert.Equal(t, 10, sl.Len())
+	assert.Equal(t, []int{0, 0, 0, 0, 1, 1, 1, 2, 2, 2}, sl.Keys())
+	assert.Equal(t, []int{0, 3, 6, 9}, sl.GetAll(0))
+	assert.Equal(t, []int{1, 4, 7}, sl.GetAll(1))
+	assert.Equal(t, []int{}, sl.GetAll(5))
+	assert.Equal(t, 4, sl.Count(0))
+	assert.Equal(t, 3, sl.Count(2))
+	assert.Equal(t, 0, sl.Count(5))
+
+	v, err := sl.Get(1)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, v)
+	rank, _ := sl.Rank(1)
+	assert.Equal(t, 4, rank)
+
+	vals := make([]int, 0)
+	sl.Range(1, Inclusive, 1, Inclusive, func(key, value int) bool {
+		vals = append(vals, value)
+		return true
+	})
+	assert.Equal(t, []int{1, 4, 7}, vals)
+
+	assert.True(t, sl.RemoveOne(0, func(v int) bool { return v == 6 }))
+	assert.False(t, sl.RemoveOne(0, func(v int) bool { return v == 6 }))
+	assert.False(t, sl.RemoveOne(5, func(v int) bool { return true }))
+	assert.Equal(t, []int{0, 3, 9}, sl.GetAll(0))
+
+	assert.True(t, sl.Remove(0))
+	assert.Equal(t, []int{3, 9}, sl.GetAll(0))
+
+	k, v, _ := sl.PopLast()
+	assert.Equal(t, 2, k)
+	assert.Equal(t, 8, v)
+	k, v, _ = sl.PopFirst()
+	assert.Equal(t, 0, k)
+	assert.Equal(t, 3, v)
+	assert.Equal(t, 6, sl.Len())
+	assert.Equal(t, []int{0, 1, 1, 1, 2, 2}, sl.Keys())
+
+	for i := 0; i < sl.Len(); i++ {
+		_, v, _ := sl.At(i)
+		assert.Equal(t, []int{9, 1, 4, 7, 2, 5}[i], v)
+	}
+
+	it := sl.Iterator()
+	n := 0
+	for ok := it.SeekToLast(); ok; ok = it.Prev() {
+		n++
+	}
+	assert.Equal(t, 6, n)
+}
+
+func TestDuplicatesRandom(t *testing.T) {
+	sl := New[int, int](comparator.OrderedTypeCmp[int], WithDuplicates())
+	m := make(map[int][]int)
+	for i := 0; i < 3000; i++ {
+		key := rand.Int() % 50
+		if i%3 == 0 && len(m[key]) > 0 {
+			j := rand.Int() % len(m[key])
+			target := m[key][j]
+			assert.True(t, sl.RemoveOne(key, func(v int) bool { return v == target }))
+			m[key] = append(m[key][:j], m[key][j+1:]...)
+		} else {
+			sl.Insert(key, i)
+			m[key] = append(m[key], i)
+		}
+	}
+	total := 0
+	for key, vals := range m {
+		assert.Equal(t, vals, sl.GetAll(key))
+		assert.Equal(t, len(vals), sl.Count(key))
+		total += len(vals)
+	}
+	assert.Equal(t, total, sl.Len())
+	keys := sl.Keys()
+	for i, key := range keys {
+		k, _, _ := sl.At(i)
+		assert.Equal(t, key, k)
+	}
+}
+
+func TestSerialization(t *testing.T) {
+	sl := New[int64, string](comparator.OrderedTypeCmp[int64], WithCodec[int64, string](BinaryCodec[int64]{}, StringCodec{}))
+	for i := 0; i < 1000; i++ {
+		key := rand.Int63() % 5000
+		sl.Insert(key, strconv.Itoa(int(key)))
+	}
+	buf := new(bytes.Buffer)
+	n, err := sl.WriteTo(buf)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(buf.Len()), n)
+	data := buf.Bytes()
+
+	restored := New[int64, string](comparator.OrderedTypeCmp[int64], WithCodec[int64, string](BinaryCodec[int64]{}, StringCodec{}))
+	restored.Insert(-1, "stale")
+	n, err = restored.ReadFrom(bytes.NewReader(data))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(len(data)), n)
+	assert.Equal(t, sl.Len(), restored.Len())
+	assert.Equal(t, sl.Keys(), restored.Keys())
+	for i, key := range restored.Keys() {
+		v, err := restored.Get(key)
+		assert.Nil(t, err)
+		assert.Equal(t, strconv.Itoa(int(key)), v)
+		rank, _ := restored.Rank(key)
+		assert.Equal(t, i, rank)
+	}
+	k, _, _ := restored.Last()
+	assert.Equal(t, sl.Keys()[sl.Len()-1], k)
+
+	corrupted := append([]byte{}, data...)
+	corrupted[20] ^= 0xff
+	_, err = restored.ReadFrom(bytes.NewReader(corrupted))
+	assert.NotNil(t, err)
+	assert.Equal(t, sl.Keys(), restored.Keys())
+
+	_, err = restored.ReadFrom(bytes.NewReader(data[:len(data)-3]))
+	assert.NotNil(t, err)
+	_, err = restored.ReadFrom(bytes.NewReader([]byte("nope")))
+	assert.Equal(t, ErrInvalidFormat, err)
+
+	noCodec := New[int, int](comparator.OrderedTypeCmp[int])
+	_, err = noCodec.WriteTo(buf)
+	assert.Equal(t, ErrNoCodec, err)
+	_, err = noCodec.ReadFrom(buf)
+	assert.Equal(t, ErrNoCodec, err)
+
+	assert.Panics(t, func() {
+		New[int, int](comparator.OrderedTypeCmp[int], WithCodec[string, int](StringCodec{}, BinaryCodec[int]{}))
+	})
+}
+
+func TestCorruptedLength(t *testing.T) {
+	// a length of 0xffffffff followed by a few bytes must fail without allocating 4 GiB
+	data := []byte{0xff, 0xff, 0xff, 0xff, 'a', 'b'}
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	_, err := BytesCodec{}.Decode(bytes.NewReader(data))
+	runtime.ReadMemStats(&after)
+	assert.True(t, errors.Is(err, io.ErrUnexpectedEOF))
+	assert.Less(t, after.TotalAlloc-before.TotalAlloc, uint64(1<<20))
+	_, err = StringCodec{}.Decode(bytes.NewReader(data))
+	assert.True(t, errors.Is(err, io.ErrUnexpectedEOF))
+
+	v, err := BytesCodec{}.Decode(bytes.NewReader([]byte{2, 0, 0, 0, 'a', 'b'}))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("ab"), v)
+}
+
+func TestBulkLoad(t *testing.T) {
+	sl := New[int, int](comparator.OrderedTypeCmp[int], WithMaxLevel(6))
+	assert.Equal(t, ErrUnsorted, sl.BulkLoad([]int{1, 3, 2}, []int{1, 3, 2}))
+	assert.Equal(t, ErrUnsorted, sl.BulkLoad([]int{1, 1}, []int{1, 1}))
+	assert.NotNil(t, sl.BulkLoad([]int{1}, []int{}))
+
+	keys := make([]int, 0)
+	for i := 0; i < 500; i++ {
+		keys = append(keys, i*2)
+	}
+	assert.Nil(t, sl.BulkLoad(keys, keys))
+	assert.Equal(t, keys, sl.Keys())
+	for i := 0; i < 200; i++ {
+		sl.Insert(rand.Int()%1000, 0)
+		sl.Remove(rand.Int() % 1000)
+	}
+	for i, key := range sl.Keys() {
+		rank, err := sl.Rank(key)
+		assert.Nil(t, err)
+		assert.Equal(t, i, rank)
+		k, _, _ := sl.At(i)
+		assert.Equal(t, key, k)
+	}
+
+	assert.Nil(t, sl.BulkLoad(nil, nil))
+	assert.Equal(t, 0, sl.Len())
+	_, _, err := sl.First()
+	assert.Equal(t, ErrNotFound, err)
+
+	dup := New[int, int](comparator.OrderedTypeCmp[int], WithDuplicates())
+	assert.Nil(t, dup.BulkLoad([]int{1, 1, 2}, []int{1, 2, 3}))
+	assert.Equal(t, []int{1, 2}, dup.GetAll(1))
+}
+
+func levels[K, V any](sl *SkipList[K, V]) []int {
+	ret := make([]int, 0, sl.len)
+	for e := sl.head.next[0]; e != nil; e = e.next[0] {
+		ret = append(ret, len(e.next))
+	}
+	return ret
+}
+
+func TestLevelGeneration(t *testing.T) {
+	a := New[int, int](comparator.OrderedTypeCmp[int], WithSeed(42))
+	b := New[int, int](comparator.OrderedTypeCmp[int], WithRandSource(rand.NewSource(42)))
+	for i := 0; i < 1000; i++ {
+		a.Insert(i, i)
+		b.Insert(i, i)
+	}
+	assert.Equal(t, levels(a), levels(b))
+
+	for _, p := range []float64{ProbabilityHalf, ProbabilityQuarter, ProbabilityInvE} {
+		sl := New[int, int](comparator.OrderedTypeCmp[int], WithProbability(p), WithSeed(1))
+		for i := 0; i < 20000; i++ {
+			sl.Insert(i, i)
+		}
+		total := 0
+		for _, level := range levels(sl) {
+			total += level
+		}
+		// the expected level is 1/(1-p)
+		assert.InDelta(t, 1/(1-p), float64(total)/20000, 0.05)
+
+		keys := make([]int, 100)
+		for i := range keys {
+			keys[i] = i
+		}
+		assert.Nil(t, sl.BulkLoad(keys, keys))
+		for i, key := range sl.Keys() {
+			rank, _ := sl.Rank(key)
+			assert.Equal(t, i, rank)
+		}
+	}
+
+	assert.Panics(t, func() { New[int, int](comparator.OrderedTypeCmp[int], WithProbability(1)) })
+	assert.Panics(t, func() { NewConcurrent[int, int](comparator.OrderedTypeCmp[int], WithProbability(0)) })
+}
+
+func TestAdaptiveMaxLevel(t *testing.T) {
+	fixed := New[int, int](comparator.OrderedTypeCmp[int], WithMaxLevel(4))
+	sl := New[int, int](comparator.OrderedTypeCmp[int], WithProbability(ProbabilityHalf))
+	assert.Equal(t, defaultMaxLevel, sl.maxLevel)
+	n := 1<<defaultMaxLevel + 10
+	for i := 0; i < n; i++ {
+		sl.Insert(i, i)
+		if i < 100 {
+			fixed.Insert(i, i)
+		}
+	}
+	assert.Equal(t, defaultMaxLevel+1, sl.maxLevel)
+	assert.Equal(t, 4, fixed.maxLevel)
+	for _, i := range []int{0, 1, n / 2, n - 1} {
+		rank, err := sl.Rank(i)
+		assert.Nil(t, err)
+		assert.Equal(t, i, rank)
+	}
+	k, _, _ := sl.At(n - 1)
+	assert.Equal(t, n-1, k)
+}
+
+func checkConsistent[V any](t *testing.T, sl *SkipList[int, V], expected []int) {
+	assert.Equal(t, len(expected), sl.Len())
+	assert.Equal(t, expected, sl.Keys())
+	for i, key := range expected {
+		k, _, err := sl.At(i)
+		assert.Nil(t, err)
+		assert.Equal(t, key, k)
+		if i == 0 || expected[i-1] != key {
+			rank, _ := sl.Rank(key)
+			assert.Equal(t, i, rank)
+		}
+	}
+	reversed := make([]int, 0)
+	it := sl.Iterator()
+	for ok := it.SeekToLast(); ok; ok = it.Prev() {
+		reversed = append([]int{it.Key()}, reversed...)
+	}
+	assert.Equal(t, expected, reversed)
+}
+
+func TestSplitAndMerge(t *testing.T) {
+	sl := New[int, int](comparator.OrderedTypeCmp[int], WithGoroutineSafe())
+	keys := make([]int, 0)
+	for i := 0; i < 300; i++ {
+		sl.Insert(i*2, i)
+		keys = append(keys, i*2)
+	}
+
+	right := sl.SplitAt(201)
+	checkConsistent(t, sl, keys[:101])
+	checkConsistent(t, right, keys[101:])
+	right.Insert(201, 0)
+	right.Remove(201)
+
+	empty := sl.SplitAt(10000)
+	checkConsistent(t, empty, []int{})
+	all := empty.SplitAt(-1)
+	checkConsistent(t, all, []int{})
+
+	assert.Equal(t, ErrOverlap, sl.Merge(sl))
+	overlap := New[int, int](comparator.OrderedTypeCmp[int])
+	overlap.Insert(100, 0)
+	assert.Equal(t, ErrOverlap, sl.Merge(overlap))
+	assert.Equal(t, 1, overlap.Len())
+
+	assert.Nil(t, sl.Merge(right))
+	checkConsistent(t, sl, keys)
+	checkConsistent(t, right, []int{})
+
+	left := sl.SplitAt(100)
+	left, sl = sl, left
+	checkConsistent(t, left, keys[:50])
+	assert.Nil(t, sl.Merge(left))
+	checkConsistent(t, sl, keys)
+	checkConsistent(t, left, []int{})
+	assert.Nil(t, sl.Merge(left))
+	assert.Nil(t, left.Merge(sl))
+	checkConsistent(t, left, keys)
+
+	for i := 0; i < 200; i++ {
+		left.Insert(rand.Int()%700, 0)
+		left.Remove(rand.Int() % 700)
+	}
+	checkConsistent(t, left, left.Keys())
+}
+
+func TestMergeKeepsLevels(t *testing.T) {
+	linked := func(sl *SkipList[int, int], level int) int {
+		n := 0
+		for e := sl.head.next[level]; e != nil; e = e.next[level] {
+			n++
+		}
+		return n
+	}
+	for round := 0; round < 4; round++ {
+		big := New[int, int](comparator.OrderedTypeCmp[int])
+		small := New[int, int](comparator.OrderedTypeCmp[int], WithMaxLevel(2))
+		keys := make([]int, 0)
+		smallFirst := round%2 == 0
+		for i := 0; i < 5000; i++ {
+			if (i < 10) == smallFirst {
+				small.Insert(i, 0)
+			} else {
+				big.Insert(i, 0)
+			}
+			keys = append(keys, i)
+		}
+		levels := big.maxLevel
+		top := linked(big, levels-1)
+
+		if round < 2 {
+			// an adaptive list takes over the levels of the other
+			assert.Nil(t, big.Merge(small))
+			checkConsistent(t, big, keys)
+			assert.Equal(t, levels, big.maxLevel)
+			assert.Equal(t, top, linked(big, levels-1))
+			assert.True(t, big.adaptive)
+			assert.Equal(t, 2, small.maxLevel)
+		} else {
+			// a list created WithMaxLevel keeps its limit
+			assert.Nil(t, small.Merge(big))
+			checkConsistent(t, small, keys)
+			assert.Equal(t, 2, small.maxLevel)
+			for e := small.head.next[0]; e != nil; e = e.next[0] {
+				assert.LessOrEqual(t, len(e.next), 2)
+			}
+			assert.Equal(t, levels, big.maxLevel)
+			for i := 5000; i < 5100; i++ {
+				small.Insert(i, 0)
+				small.Remove(i - 4000)
+			}
+			assert.Equal(t, 2, small.maxLevel)
+			checkConsistent(t, small, small.Keys())
+		}
+	}
+
+	// an adaptive list keeps growing after taking over the levels of a fixed one
+	big := New[int, int](comparator.OrderedTypeCmp[int])
+	small := New[int, int](comparator.OrderedTypeCmp[int], WithMaxLevel(2))
+	small.Insert(0, 0)
+	big.Insert(1, 0)
+	assert.Nil(t, big.Merge(small))
+	for i := 2; i < 100000; i++ {
+		big.Insert(i, 0)
+	}
+	assert.Greater(t, big.maxLevel, defaultMaxLevel)
+}
+
+func TestConcurrentMerge(t *testing.T) {
+	for round := 0; round < 100; round++ {
+		a := New[int, int](comparator.OrderedTypeCmp[int], WithGoroutineSafe())
+		b := New[int, int](comparator.OrderedTypeCmp[int], WithGoroutineSafe())
+		a.Insert(1, 0)
+		b.Insert(2, 0)
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			a.Merge(b)
+		}()
+		go func() {
+			defer wg.Done()
+			b.Merge(a)
+		}()
+		wg.Wait()
+		assert.Equal(t, 2, a.Len()+b.Len())
+	}
+}
+
+func TestRemoveRange(t *testing.T) {
+	for round := 0; round < 20; round++ {
+		sl := New[int, int](comparator.OrderedTypeCmp[int], WithMaxLevel(6))
+		for i := 0; i < 200; i++ {
+			sl.Insert(rand.Int()%300, i)
+		}
+		lo, hi := rand.Int()%320-10, rand.Int()%320-10
+		expected := make([]int, 0)
+		removed := 0
+		for _, key := range sl.Keys() {
+			if key >= lo && key < hi {
+				removed++
+			} else {
+				expected = append(expected, key)
+			}
+		}
+		assert.Equal(t, removed, sl.RemoveRange(lo, hi))
+		checkConsistent(t, sl, expected)
+		sl.Insert(lo, 0)
+		sl.Remove(hi)
+		checkConsistent(t, sl, sl.Keys())
+	}
+
+	dup := New[int, int](comparator.OrderedTypeCmp[int], WithDuplicates())
+	for i := 0; i < 30; i++ {
+		dup.Insert(i%5, i)
+	}
+	assert.Equal(t, 12, dup.RemoveRange(1, 3))
+	checkConsistent(t, dup, []int{0, 0, 0, 0, 0, 0, 3, 3, 3, 3, 3, 3, 4, 4, 4, 4, 4, 4})
+	assert.Equal(t, 0, dup.RemoveRange(3, 3))
+}
@@ -0,0 +1,198 @@
+package skiplist
+
+import (
+	"errors"
+	"goalds/utils/locker"
+	"math/rand"
+	"sync"
+	"unsafe"
+)
+
+var ErrOverlap = errors.New("skiplist: key ranges overlap")
+
+// SplitAt moves every element whose key is >= key into a new list and returns it.
+// The new list has the same options as sl. It runs in O(log n).
+func (sl *SkipList[K, V]) SplitAt(key K) *SkipList[K, V] {
+	sl.locker.Lock()
+	defer sl.locker.Unlock()
+
+	other := sl.newEmpty()
+	prevs, ranks := sl.findPrevNodes(key, Inclusive)
+	kept := ranks[0]
+	for i := 0; i < sl.maxLevel; i++ {
+		other.head.next[i] = prevs[i].next[i]
+		other.head.span[i] = prevs[i].span[i] - (kept - ranks[i])
+		prevs[i].next[i] = nil
+		prevs[i].span[i] = kept - ranks[i]
+	}
+	if first := other.head.next[0]; first != nil {
+		other.tail = sl.tail
+		sl.tail = first.prev
+		first.prev = nil
+	}
+	other.len = sl.len - kept
+	sl.len = kept
+	return other
+}
+
+// Merge moves every element of other into sl and leaves other empty.
+// All keys of other must be greater than all keys of sl or all smaller, otherwise ErrOverlap is returned.
+// Both lists keep their level limits: an adaptive sl takes over the extra levels of other, and
+// an sl created WithMaxLevel cuts the towers of other down to its own levels.
+// It runs in O(log n) plus the number of cut towers. Both lists are locked in address order,
+// so a.Merge(b) and b.Merge(a) may run concurrently.
+func (sl *SkipList[K, V]) Merge(other *SkipList[K, V]) error {
+	if sl == other {
+		return ErrOverlap
+	}
+	first, second := sl, other
+	if uintptr(unsafe.Pointer(second)) < uintptr(unsafe.Pointer(first)) {
+		first, second = second, first
+	}
+	first.locker.Lock()
+	defer first.locker.Unlock()
+	second.locker.Lock()
+	defer second.locker.Unlock()
+
+	if other.len == 0 {
+		return nil
+	}
+	forward := sl.len == 0 || sl.before(sl.tail.key, other.head.next[0].key)
+	if !forward && !sl.before(other.tail.key, sl.head.next[0].key) {
+		return ErrOverlap
+	}
+
+	levels, otherLevels := sl.maxLevel, other.maxLevel
+	if sl.adaptive && otherLevels > levels {
+		levels = otherLevels
+	}
+	sl.setLevels(levels)
+	other.setLevels(levels)
+	if forward {
+		sl.appendList(other)
+	} else {
+		// link sl after other and take over the result
+		other.appendList(sl)
+		sl.head, other.head = other.head, sl.head
+		sl.tail, other.tail = other.tail, sl.tail
+		sl.len, other.len = other.len, sl.len
+		sl.prevNodesCache, other.prevNodesCache = other.prevNodesCache, sl.prevNodesCache
+		sl.prevRanksCache, other.prevRanksCache = other.prevRanksCache, sl.prevRanksCache
+	}
+	other.setLevels(otherLevels)
+	sl.growTo(sl.len)
+	return nil
+}
+
+// RemoveRange removes every element whose key is in [lo, hi) and returns how many were removed.
+// It runs in O(log n) plus the garbage collection of the removed elements.
+func (sl *SkipList[K, V]) RemoveRange(lo, hi K) int {
+	sl.locker.Lock()
+	defer sl.locker.Unlock()
+
+	if sl.cmp(lo, hi) >= 0 {
+		return 0
+	}
+	prevs, ranks := sl.findPrevNodes(lo, Inclusive)
+	lows := append([]*Node[K, V](nil), prevs...)
+	lowRanks := append([]int(nil), ranks...)
+	highs, highRanks := sl.findPrevNodes(hi, Inclusive)
+	removed := highRanks[0] - lowRanks[0]
+	if removed == 0 {
+		return 0
+	}
+
+	first := lows[0].next[0]
+	if last := highs[0].next[0]; last != nil {
+		last.prev = first.prev
+	} else {
+		sl.tail = first.prev
+	}
+	for i := 0; i < sl.maxLevel; i++ {
+		lows[i].span[i] = highRanks[i] + highs[i].span[i] - lowRanks[i] - removed
+		lows[i].next[i] = highs[i].next[i]
+	}
+	sl.len -= removed
+	return removed
+}
+
+// before reports whether a list ending with key a can be followed by a list starting with key b.
+func (sl *SkipList[K, V]) before(a, b K) bool {
+	cmpRet := sl.cmp(a, b)
+	return cmpRet < 0 || (cmpRet == 0 && sl.duplicates)
+}
+
+// appendList links the elements of other after the tail of sl and empties other.
+// Both lists must have the same number of levels.
+func (sl *SkipList[K, V]) appendList(other *SkipList[K, V]) {
+	lasts := sl.prevNodesCache
+	ranks := sl.prevRanksCache
+	last := &sl.head
+	rank := 0
+	for i := sl.maxLevel - 1; i >= 0; i-- {
+		for last.next[i] != nil {
+			rank += last.span[i]
+			last = &last.next[i].Node
+		}
+		lasts[i] = last
+		ranks[i] = rank
+	}
+	for i := 0; i < sl.maxLevel; i++ {
+		lasts[i].next[i] = other.head.next[i]
+		lasts[i].span[i] = sl.len - ranks[i] + other.head.span[i]
+	}
+	if first := other.head.next[0]; first != nil {
+		first.prev = sl.tail
+		sl.tail = other.tail
+	}
+	sl.len += other.len
+
+	for i := 0; i < other.maxLevel; i++ {
+		other.head.next[i] = nil
+		other.head.span[i] = 0
+	}
+	other.tail = nil
+	other.len = 0
+}
+
+// setLevels adds levels, or removes levels and cuts the towers reaching above them, until sl has n levels.
+func (sl *SkipList[K, V]) setLevels(n int) {
+	for sl.maxLevel < n {
+		sl.addLevel()
+	}
+	if sl.maxLevel == n {
+		return
+	}
+	for e := sl.head.next[n]; e != nil; {
+		next := e.next[n]
+		e.next, e.span = e.next[:n], e.span[:n]
+		e = next
+	}
+	sl.head.next, sl.head.span = sl.head.next[:n], sl.head.span[:n]
+	sl.prevNodesCache, sl.prevRanksCache = sl.prevNodesCache[:n], sl.prevRanksCache[:n]
+	sl.maxLevel = n
+	sl.growAt = sl.capacityOf(n)
+}
+
+// newEmpty returns an empty list with the same options as sl.
+func (sl *SkipList[K, V]) newEmpty() *SkipList[K, V] {
+	var l locker.Locker = defaultLocker
+	if _, ok := sl.locker.(locker.FakeLocker); !ok {
+		l = &sync.RWMutex{}
+	}
+	return &SkipList[K, V]{
+		locker:         l,
+		maxLevel:       sl.maxLevel,
+		adaptive:       sl.adaptive,
+		growAt:         sl.growAt,
+		probability:    sl.probability,
+		duplicates:     sl.duplicates,
+		head:           Node[K, V]{next: make([]*Element[K, V], sl.maxLevel), span: make([]int, sl.maxLevel)},
+		cmp:            sl.cmp,
+		prevNodesCache: make([]*Node[K, V], sl.maxLevel),
+		prevRanksCache: make([]int, sl.maxLevel),
+		rander:         rand.New(rand.NewSource(sl.rander.Int63())),
+		keyCodec:       sl.keyCodec,
+		valCodec:       sl.valCodec,
+	}
+}
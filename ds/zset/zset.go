@@ -0,0 +1,204 @@
+package zset
+
+import (
+	gomap "goalds/ds/map"
+	"goalds/ds/skiplist"
+	"goalds/utils/comparator"
+	"goalds/utils/locker"
+	"goalds/utils/visitor"
+	"sync"
+)
+
+var defaultLocker locker.FakeLocker
+
+type Options struct {
+	locker locker.Locker
+}
+
+type Option func(option *Options)
+
+func WithGoroutineSafe() Option {
+	return func(option *Options) {
+		option.locker = &sync.RWMutex{}
+	}
+}
+
+// entry is the skiplist key. A non-zero edge turns the entry into a probe that
+// sorts before (-1) or after (+1) every member with the same score.
+type entry[T comparator.Ordered] struct {
+	score  float64
+	member T
+	edge   int
+}
+
+func entryCmp[T comparator.Ordered](a, b entry[T]) int {
+	if ret := comparator.OrderedTypeCmp(a.score, b.score); ret != 0 {
+		return ret
+	}
+	if a.edge != 0 || b.edge != 0 {
+		return a.edge - b.edge
+	}
+	return comparator.OrderedTypeCmp(a.member, b.member)
+}
+
+// ZSet is a sorted set in the style of Redis: every member has a score,
+// members are ordered by score and members with equal scores are ordered by member.
+// Scores must not be NaN.
+type ZSet[T comparator.Ordered] struct {
+	locker locker.Locker
+	sl     *skiplist.SkipList[entry[T], struct{}]
+	scores *gomap.Map[T, float64]
+}
+
+func New[T comparator.Ordered](options ...Option) *ZSet[T] {
+	opt := &Options{locker: defaultLocker}
+	for _, option := range options {
+		option(opt)
+	}
+	return &ZSet[T]{
+		locker: opt.locker,
+		sl:     skiplist.New[entry[T], struct{}](entryCmp[T]),
+		scores: gomap.New[T, float64](),
+	}
+}
+
+// Add sets the score of member, like ZADD. It returns true if member was not in the set.
+func (z *ZSet[T]) Add(member T, score float64) bool {
+	z.locker.Lock()
+	defer z.locker.Unlock()
+
+	return z.add(member, score)
+}
+
+// Remove deletes member, like ZREM. It returns false if member was not in the set.
+func (z *ZSet[T]) Remove(member T) bool {
+	z.locker.Lock()
+	defer z.locker.Unlock()
+
+	score, ok := z.scores.Get(member)
+	if !ok {
+		return false
+	}
+	z.sl.Remove(entry[T]{score: score, member: member})
+	z.scores.Erase(member)
+	return true
+}
+
+// Score returns the score of member, like ZSCORE.
+func (z *ZSet[T]) Score(member T) (float64, error) {
+	z.locker.RLock()
+	defer z.locker.RUnlock()
+
+	score, ok := z.scores.Get(member)
+	if !ok {
+		return 0, skiplist.ErrNotFound
+	}
+	return score, nil
+}
+
+// IncrBy adds delta to the score of member and returns the new score, like ZINCRBY.
+// A missing member is added with delta as its score.
+func (z *ZSet[T]) IncrBy(member T, delta float64) float64 {
+	z.locker.Lock()
+	defer z.locker.Unlock()
+
+	score, _ := z.scores.Get(member)
+	score += delta
+	z.add(member, score)
+	return score
+}
+
+// Rank returns the 0-based position of member ordered by ascending score, like ZRANK.
+func (z *ZSet[T]) Rank(member T) (int, error) {
+	z.locker.RLock()
+	defer z.locker.RUnlock()
+
+	score, ok := z.scores.Get(member)
+	if !ok {
+		return -1, skiplist.ErrNotFound
+	}
+	return z.sl.Rank(entry[T]{score: score, member: member})
+}
+
+// RevRank returns the 0-based position of member ordered by descending score, like ZREVRANK.
+func (z *ZSet[T]) RevRank(member T) (int, error) {
+	z.locker.RLock()
+	defer z.locker.RUnlock()
+
+	score, ok := z.scores.Get(member)
+	if !ok {
+		return -1, skiplist.ErrNotFound
+	}
+	rank, err := z.sl.Rank(entry[T]{score: score, member: member})
+	if err != nil {
+		return -1, err
+	}
+	return z.sl.Len() - rank - 1, nil
+}
+
+// Range calls visitor for the members whose ranks are in [start, stop], like ZRANGE.
+// Negative ranks count from the end.
+func (z *ZSet[T]) Range(start, stop int, visitor visitor.KVVisitor[T, float64]) {
+	z.locker.RLock()
+	defer z.locker.RUnlock()
+
+	z.sl.RangeByRank(start, stop, func(e entry[T], _ struct{}) bool {
+		return visitor(e.member, e.score)
+	})
+}
+
+// RangeByScore calls visitor for the members whose scores are between min and max, like ZRANGEBYSCORE.
+func (z *ZSet[T]) RangeByScore(min float64, minBound skiplist.Bound, max float64, maxBound skiplist.Bound, visitor visitor.KVVisitor[T, float64]) {
+	z.locker.RLock()
+	defer z.locker.RUnlock()
+
+	lo := entry[T]{score: min, edge: -1}
+	if minBound == skiplist.Exclusive {
+		lo.edge = 1
+	}
+	hi := entry[T]{score: max, edge: 1}
+	if maxBound == skiplist.Exclusive {
+		hi.edge = -1
+	}
+	z.sl.Range(lo, skiplist.Inclusive, hi, skiplist.Inclusive, func(e entry[T], _ struct{}) bool {
+		return visitor(e.member, e.score)
+	})
+}
+
+// RangeByLex calls visitor for the members between min and max, like ZRANGEBYLEX.
+// As in Redis, the result is only meaningful when all members share the same score.
+func (z *ZSet[T]) RangeByLex(min T, minBound skiplist.Bound, max T, maxBound skiplist.Bound, visitor visitor.KVVisitor[T, float64]) {
+	z.locker.RLock()
+	defer z.locker.RUnlock()
+
+	first, _, err := z.sl.First()
+	if err != nil {
+		return
+	}
+	lo := entry[T]{score: first.score, member: min}
+	hi := entry[T]{score: first.score, member: max}
+	z.sl.Range(lo, minBound, hi, maxBound, func(e entry[T], _ struct{}) bool {
+		return visitor(e.member, e.score)
+	})
+}
+
+// Len returns the number of members, like ZCARD.
+func (z *ZSet[T]) Len() int {
+	z.locker.RLock()
+	defer z.locker.RUnlock()
+
+	return z.sl.Len()
+}
+
+func (z *ZSet[T]) add(member T, score float64) bool {
+	old, ok := z.scores.Get(member)
+	if ok {
+		if old == score {
+			return false
+		}
+		z.sl.Remove(entry[T]{score: old, member: member})
+	}
+	z.sl.Insert(entry[T]{score: score, member: member}, struct{}{})
+	z.scores.Set(member, score)
+	return !ok
+}
@@ -0,0 +1,94 @@
+package zset
+
+import (
+	"goalds/ds/skiplist"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func collect[T int | string](f func(visitor func(member T, score float64) bool)) []T {
+	members := make([]T, 0)
+	f(func(member T, score float64) bool {
+		members = append(members, member)
+		return true
+	})
+	return members
+}
+
+func TestAddAndRemove(t *testing.T) {
+	z := New[string](WithGoroutineSafe())
+	assert.True(t, z.Add("a", 3))
+	assert.True(t, z.Add("b", 1))
+	assert.True(t, z.Add("c", 2))
+	assert.False(t, z.Add("a", 0))
+	assert.Equal(t, 3, z.Len())
+
+	score, err := z.Score("a")
+	assert.Nil(t, err)
+	assert.Equal(t, 0.0, score)
+	_, err = z.Score("d")
+	assert.Equal(t, skiplist.ErrNotFound, err)
+
+	assert.Equal(t, []string{"a", "b", "c"}, collect[string](func(v func(string, float64) bool) { z.Range(0, -1, v) }))
+
+	assert.True(t, z.Remove("b"))
+	assert.False(t, z.Remove("b"))
+	assert.Equal(t, 2, z.Len())
+	assert.Equal(t, []string{"a", "c"}, collect[string](func(v func(string, float64) bool) { z.Range(0, -1, v) }))
+}
+
+func TestIncrByAndRank(t *testing.T) {
+	z := New[string]()
+	assert.Equal(t, 5.0, z.IncrBy("a", 5))
+	assert.Equal(t, 7.0, z.IncrBy("a", 2))
+	z.Add("b", 7)
+	z.Add("c", 1)
+
+	rank, err := z.Rank("c")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, rank)
+	rank, _ = z.Rank("a")
+	assert.Equal(t, 1, rank)
+	rank, _ = z.Rank("b")
+	assert.Equal(t, 2, rank)
+	rank, _ = z.RevRank("b")
+	assert.Equal(t, 0, rank)
+	_, err = z.Rank("d")
+	assert.Equal(t, skiplist.ErrNotFound, err)
+
+	z.IncrBy("c", 10)
+	rank, _ = z.Rank("c")
+	assert.Equal(t, 2, rank)
+}
+
+func TestRangeByScore(t *testing.T) {
+	z := New[int]()
+	for i := -5; i < 5; i++ {
+		z.Add(i, float64(i/2))
+	}
+	rangeByScore := func(min float64, minBound skiplist.Bound, max float64, maxBound skiplist.Bound) []int {
+		return collect[int](func(v func(int, float64) bool) { z.RangeByScore(min, minBound, max, maxBound, v) })
+	}
+	assert.Equal(t, []int{-3, -2, -1, 0, 1}, rangeByScore(-1, skiplist.Inclusive, 0, skiplist.Inclusive))
+	assert.Equal(t, []int{-1, 0, 1}, rangeByScore(-1, skiplist.Exclusive, 0, skiplist.Inclusive))
+	assert.Equal(t, []int{-3, -2}, rangeByScore(-1, skiplist.Inclusive, 0, skiplist.Exclusive))
+	assert.Equal(t, []int{}, rangeByScore(-1, skiplist.Exclusive, 0, skiplist.Exclusive))
+	assert.Equal(t, []int{-5, -4, -3, -2, -1, 0, 1, 2, 3, 4}, rangeByScore(-100, skiplist.Inclusive, 100, skiplist.Inclusive))
+}
+
+func TestRangeByLex(t *testing.T) {
+	z := New[string]()
+	for _, m := range []string{"a", "b", "c", "d", "e", "f", "g"} {
+		z.Add(m, 0)
+	}
+	rangeByLex := func(min string, minBound skiplist.Bound, max string, maxBound skiplist.Bound) []string {
+		return collect[string](func(v func(string, float64) bool) { z.RangeByLex(min, minBound, max, maxBound, v) })
+	}
+	assert.Equal(t, []string{"a", "b", "c"}, rangeByLex("", skiplist.Inclusive, "c", skiplist.Inclusive))
+	assert.Equal(t, []string{"a", "b"}, rangeByLex("", skiplist.Inclusive, "c", skiplist.Exclusive))
+	assert.Equal(t, []string{"b", "c", "d", "e", "f"}, rangeByLex("aaa", skiplist.Inclusive, "g", skiplist.Exclusive))
+
+	z = New[string]()
+	assert.Equal(t, []string{}, rangeByLex("", skiplist.Inclusive, "z", skiplist.Inclusive))
+}
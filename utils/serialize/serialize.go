@@ -0,0 +1,53 @@
+package serialize
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// CountingWriter counts the bytes written to W in N.
+type CountingWriter struct {
+	W io.Writer
+	N int64
+}
+
+func (cw *CountingWriter) Write(p []byte) (int, error) {
+	n, err := cw.W.Write(p)
+	cw.N += int64(n)
+	return n, err
+}
+
+// CountingReader counts the bytes read from R in N.
+type CountingReader struct {
+	R io.Reader
+	N int64
+}
+
+func (cr *CountingReader) Read(p []byte) (int, error) {
+	n, err := cr.R.Read(p)
+	cr.N += int64(n)
+	return n, err
+}
+
+// ReadN reads exactly n bytes from r, or fails with io.ErrUnexpectedEOF. It copies the bytes
+// as they arrive, so a corrupted length cannot make it allocate more memory than r holds.
+func ReadN(r io.Reader, n int64) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if _, err := io.CopyN(buf, r, n); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Truncated wraps err, from input that ended early, as "pkg: truncated data".
+// io.EOF becomes io.ErrUnexpectedEOF.
+func Truncated(pkg string, err error) error {
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return fmt.Errorf("%s: truncated data: %w", pkg, err)
+}